@@ -0,0 +1,85 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"github.com/invinciblewest/gophermart/internal/accrualfake"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func main() {
+	var (
+		address        string
+		rules          string
+		defaultAccrual float64
+		processingIn   time.Duration
+		processedIn    time.Duration
+		errorRate      float64
+		throttleEvery  int
+		retryAfter     time.Duration
+	)
+
+	flag.StringVar(&address, "a", "localhost:8081", "server address")
+	flag.StringVar(&rules, "rules", "", "reward rules as prefix=accrual pairs, use prefix=invalid to reject orders")
+	flag.Float64Var(&defaultAccrual, "accrual", 100, "accrual for orders matching no rule")
+	flag.DurationVar(&processingIn, "processing-after", time.Second, "delay before an order becomes PROCESSING")
+	flag.DurationVar(&processedIn, "processed-after", 2*time.Second, "delay before an order reaches its final status")
+	flag.Float64Var(&errorRate, "error-rate", 0, "share of requests answered with 500")
+	flag.IntVar(&throttleEvery, "throttle-every", 0, "answer every n-th request with 429")
+	flag.DurationVar(&retryAfter, "retry-after", 5*time.Second, "Retry-After sent with 429 responses")
+	flag.Parse()
+
+	parsedRules, err := parseRules(rules)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	server := accrualfake.New(accrualfake.Options{
+		Rules:          parsedRules,
+		DefaultAccrual: defaultAccrual,
+		Steps: []accrualfake.Step{
+			{Status: accrualfake.StatusRegistered},
+			{Status: accrualfake.StatusProcessing, After: processingIn},
+			{Status: accrualfake.StatusProcessed, After: processedIn},
+		},
+		AutoRegister:  true,
+		ErrorRate:     errorRate,
+		ThrottleEvery: throttleEvery,
+		RetryAfter:    retryAfter,
+	})
+
+	log.Println("fake accrual service is starting on", address)
+	if err = http.ListenAndServe(address, server); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal(err)
+	}
+}
+
+func parseRules(value string) ([]accrualfake.Rule, error) {
+	var rules []accrualfake.Rule
+	for _, pair := range strings.Split(value, ",") {
+		if pair == "" {
+			continue
+		}
+
+		prefix, reward, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, errors.New("invalid rule " + pair)
+		}
+
+		if reward == "invalid" {
+			rules = append(rules, accrualfake.Rule{Prefix: prefix, Invalid: true})
+			continue
+		}
+
+		accrual, err := strconv.ParseFloat(reward, 64)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, accrualfake.Rule{Prefix: prefix, Accrual: accrual})
+	}
+	return rules, nil
+}
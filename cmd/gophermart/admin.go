@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/invinciblewest/gophermart/internal/config"
+	"github.com/invinciblewest/gophermart/internal/logger"
+	"github.com/invinciblewest/gophermart/internal/model"
+	"github.com/invinciblewest/gophermart/internal/repository/postgres"
+	"github.com/invinciblewest/gophermart/internal/usecase/app"
+	"go.uber.org/zap"
+	"golang.org/x/term"
+	"os"
+	"strings"
+)
+
+const adminUsage = `usage: gophermart [flags] admin <command>
+
+commands:
+  migrate up|down|status      apply, roll back one or list migrations
+  user create <login>         create a user, the password is read from stdin
+  user disable <login>        disable a user and revoke their refresh tokens
+  user reset-password <login> set a new password read from stdin and revoke refresh tokens
+  orders requeue <number>     schedule an order for another accrual lookup
+  balance check               list balances that drifted from the ledger
+  balance recompute <login>   rebuild a user's balance from the ledger, --all rebuilds every drifted balance
+  export                      write all users with balances, orders and withdrawals as JSON lines`
+
+var adminCommands = map[string]int{
+	"migrate up":          0,
+	"migrate down":        0,
+	"migrate status":      0,
+	"user create":         1,
+	"user disable":        1,
+	"user reset-password": 1,
+	"orders requeue":      1,
+	"balance check":       0,
+	"balance recompute":   1,
+	"export":              0,
+}
+
+var (
+	errInvalidAdminCommand = errors.New("invalid admin command")
+	errBalanceDrift        = errors.New("balances drifted from the ledger")
+)
+
+func runAdmin(ctx context.Context, cfg config.Config, args []string) error {
+	command, params, ok := parseAdminCommand(args)
+	if !ok {
+		fmt.Fprintln(os.Stderr, adminUsage)
+		return errInvalidAdminCommand
+	}
+
+	db, err := sql.Open("postgres", cfg.DatabaseURL)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer func(db *sql.DB) {
+		if err := db.Close(); err != nil {
+			logger.Log.Error("failed to close database", zap.Error(err))
+		}
+	}(db)
+
+	if err = db.PingContext(ctx); err != nil {
+		return fmt.Errorf("failed to ping database: %w", err)
+	}
+
+	repository := postgres.NewPGRepository(db)
+	authUseCase := app.NewAuthUseCase(cfg.SecretKey, nil, repository, cfg.AccessTokenTTL, cfg.RefreshTokenTTL)
+	adminUseCase := app.NewAdminUseCase(repository, repository, repository, repository, repository, authUseCase)
+
+	switch command {
+	case "migrate up", "migrate down", "migrate status":
+		return runMigrations(db, strings.TrimPrefix(command, "migrate "))
+	case "user create":
+		password, err := readPassword()
+		if err != nil {
+			return err
+		}
+		user, err := adminUseCase.CreateUser(ctx, params[0], password)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("user %s created with id %d\n", user.Login, user.ID)
+	case "user disable":
+		if err = adminUseCase.DisableUser(ctx, params[0]); err != nil {
+			return err
+		}
+		fmt.Printf("user %s disabled\n", params[0])
+	case "user reset-password":
+		password, err := readPassword()
+		if err != nil {
+			return err
+		}
+		if err = adminUseCase.ResetPassword(ctx, params[0], password); err != nil {
+			return err
+		}
+		fmt.Printf("password of %s reset\n", params[0])
+	case "orders requeue":
+		if err = adminUseCase.RequeueOrder(ctx, params[0]); err != nil {
+			return err
+		}
+		fmt.Printf("order %s requeued\n", params[0])
+	case "balance check":
+		drifts, err := adminUseCase.ReconcileBalances(ctx, false)
+		if err != nil {
+			return err
+		}
+		for _, drift := range drifts {
+			printBalanceDrift(fmt.Sprintf("user %d", drift.UserID), drift)
+		}
+		if len(drifts) > 0 {
+			return fmt.Errorf("%w: %d users", errBalanceDrift, len(drifts))
+		}
+		fmt.Println("all balances match the ledger")
+	case "balance recompute":
+		if params[0] == "--all" {
+			drifts, err := adminUseCase.ReconcileBalances(ctx, true)
+			if err != nil {
+				return err
+			}
+			for _, drift := range drifts {
+				printBalanceDrift(fmt.Sprintf("user %d", drift.UserID), drift)
+			}
+			fmt.Printf("%d balances recomputed\n", len(drifts))
+			return nil
+		}
+		drift, err := adminUseCase.RecomputeBalance(ctx, params[0])
+		if err != nil {
+			return err
+		}
+		printBalanceDrift(params[0], *drift)
+	case "export":
+		encoder := json.NewEncoder(os.Stdout)
+		return adminUseCase.Export(ctx, func(export model.UserExport) error {
+			return encoder.Encode(&export)
+		})
+	}
+
+	return nil
+}
+
+func parseAdminCommand(args []string) (string, []string, bool) {
+	for n := 1; n <= 2 && n <= len(args); n++ {
+		command := strings.Join(args[:n], " ")
+		if argc, ok := adminCommands[command]; ok && len(args)-n == argc {
+			return command, args[n:], true
+		}
+	}
+	return "", nil, false
+}
+
+func printBalanceDrift(owner string, drift model.BalanceDrift) {
+	fmt.Printf("balance of %s: current %.2f -> %.2f, withdrawn %.2f -> %.2f\n", owner,
+		float64(drift.Stored.Current)/100, float64(drift.Expected.Current)/100,
+		float64(drift.Stored.Withdrawn)/100, float64(drift.Expected.Withdrawn)/100)
+}
+
+func readPassword() (string, error) {
+	fmt.Fprint(os.Stderr, "password: ")
+
+	if fd := int(os.Stdin.Fd()); term.IsTerminal(fd) {
+		password, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", err
+		}
+		return string(password), nil
+	}
+
+	scanner := bufio.NewScanner(os.Stdin)
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return "", err
+		}
+		return "", model.ErrEmptyLoginOrPassword
+	}
+
+	return strings.TrimRight(scanner.Text(), "\r"), nil
+}
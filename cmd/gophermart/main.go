@@ -4,15 +4,27 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"flag"
+	"fmt"
+	"github.com/XSAM/otelsql"
 	"github.com/invinciblewest/gophermart/internal/client/accrual"
 	"github.com/invinciblewest/gophermart/internal/config"
 	"github.com/invinciblewest/gophermart/internal/handler"
+	"github.com/invinciblewest/gophermart/internal/keyset"
+	"github.com/invinciblewest/gophermart/internal/lifecycle"
 	"github.com/invinciblewest/gophermart/internal/logger"
+	"github.com/invinciblewest/gophermart/internal/metrics"
+	"github.com/invinciblewest/gophermart/internal/openapi"
 	"github.com/invinciblewest/gophermart/internal/repository/postgres"
+	"github.com/invinciblewest/gophermart/internal/tracing"
+	"github.com/invinciblewest/gophermart/internal/usecase"
 	"github.com/invinciblewest/gophermart/internal/usecase/app"
+	"github.com/invinciblewest/gophermart/internal/validator"
+	"github.com/invinciblewest/gophermart/migrations"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
 	"github.com/pressly/goose"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
 	"go.uber.org/zap"
 	"log"
 	"net/http"
@@ -23,6 +35,14 @@ import (
 )
 
 func main() {
+	if err := run(); err != nil {
+		logger.Log.Error("gophermart stopped with error", zap.Error(err))
+		_ = logger.Log.Sync()
+		os.Exit(1)
+	}
+}
+
+func run() error {
 	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer cancel()
 
@@ -37,81 +57,236 @@ func main() {
 		log.Fatal(err)
 	}
 
-	db, err := sql.Open("postgres", cfg.DatabaseURL)
+	if flag.Arg(0) == "admin" {
+		return runAdmin(ctx, cfg, flag.Args()[1:])
+	}
+
+	shutdownTracing, err := tracing.Setup(ctx, tracing.Options{
+		Exporter:    cfg.TraceExporter,
+		File:        cfg.TraceFile,
+		SampleRatio: cfg.TraceSampleRatio,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set up tracing: %w", err)
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			logger.Log.Error("failed to shut down tracing", zap.Error(err))
+		}
+	}()
+
+	db, err := otelsql.Open("postgres", cfg.DatabaseURL, otelsql.WithAttributes(semconv.DBSystemPostgreSQL))
 	if err != nil {
-		logger.Log.Fatal("failed to connect to database", zap.Error(err))
+		return fmt.Errorf("failed to connect to database: %w", err)
 	}
 	defer func(db *sql.DB) {
-		err = db.Close()
-		if err != nil {
-			logger.Log.Fatal("failed to close database", zap.Error(err))
+		if err := db.Close(); err != nil {
+			logger.Log.Error("failed to close database", zap.Error(err))
 		}
 	}(db)
 
-	logger.Log.Info("attempting to connect to database...", zap.String("url", cfg.DatabaseURL))
+	logger.Log.Info("attempting to connect to database...", zap.String("url", logger.RedactDSN(cfg.DatabaseURL)))
 	if err = db.Ping(); err != nil {
-		logger.Log.Fatal("failed to ping database", zap.Error(err))
+		return fmt.Errorf("failed to ping database: %w", err)
+	}
+
+	if cfg.SkipMigrations {
+		logger.Log.Info("skipping migrations on startup")
+	} else if err = runMigrations(db, "up"); err != nil {
+		return fmt.Errorf("failed to run migrations: %w", err)
 	}
 
-	if err = runMigrations(db); err != nil {
-		logger.Log.Fatal("failed to run migrations", zap.Error(err))
+	if err = metrics.RegisterDB(db); err != nil {
+		return fmt.Errorf("failed to register database metrics: %w", err)
 	}
 
-	accrualClient := accrual.NewClient(cfg.AccrualSystemAddress)
+	accrualClient := accrual.NewClient(cfg.AccrualSystemAddress, accrual.Options{
+		Timeout:                 cfg.AccrualTimeout,
+		RateLimit:               cfg.AccrualRateLimit,
+		RateBurst:               cfg.AccrualRateBurst,
+		BreakerThreshold:        cfg.BreakerThreshold,
+		BreakerOpenTimeout:      cfg.BreakerOpenTimeout,
+		BreakerHalfOpenRequests: cfg.BreakerHalfOpenMax,
+	})
 
 	repository := postgres.NewPGRepository(db)
 
-	authUseCase := app.NewAuthUseCase(cfg.SecretKey)
+	var keySet *keyset.KeySet
+	if cfg.JWTKeysDir != "" {
+		keySet, err = keyset.Load(cfg.JWTKeysDir, cfg.JWTSigningKeyID)
+		if err != nil {
+			return fmt.Errorf("failed to load JWT keys: %w", err)
+		}
+	}
+
+	authUseCase := app.NewAuthUseCase(cfg.SecretKey, keySet, repository, cfg.AccessTokenTTL, cfg.RefreshTokenTTL)
 	userUseCase := app.NewUserUseCase(repository, authUseCase)
-	orderUseCase := app.NewOrderUseCase(repository)
-	balanceUseCase := app.NewBalanceUseCase(repository, repository)
+	orderNumberValidator, err := validator.Parse(cfg.OrderNumberRules)
+	if err != nil {
+		return fmt.Errorf("failed to parse order number rules: %w", err)
+	}
+
+	orderUseCase := app.NewOrderUseCase(repository, orderNumberValidator, cfg.OrderBatchMaxSize)
+	balanceUseCase := app.NewBalanceUseCase(repository, repository, orderNumberValidator)
+	idempotencyUseCase := app.NewIdempotencyUseCase(repository, cfg.IdempotencyLease, cfg.IdempotencyKeyTTL)
+
+	accrualProcessor := app.NewAccrualProcessor(repository, repository, accrualClient, app.AccrualProcessorOptions{
+		BatchSize:   cfg.AccrualBatchSize,
+		Lease:       cfg.AccrualJobLease,
+		BackoffBase: cfg.AccrualBackoffBase,
+		BackoffMax:  cfg.AccrualBackoffMax,
+		MaxAge:      cfg.AccrualMaxAge,
+		Timeout:     cfg.AccrualTimeout,
+	})
 
-	accrualProcessor := app.NewAccrualProcessor(repository, accrualClient)
+	migrationVersion, err := migrations.LatestVersion()
+	if err != nil {
+		return fmt.Errorf("failed to read migrations: %w", err)
+	}
+	maxTickAge := 3*time.Duration(cfg.UpdateInterval)*time.Second + cfg.AccrualJobLease
+	healthUseCase := app.NewHealthUseCase(repository, accrualClient, accrualProcessor, migrationVersion, maxTickAge)
 
-	go accrualProcessor.Run(ctx, cfg.UpdateInterval, cfg.WorkerCount)
+	apiSpec, err := openapi.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load openapi spec: %w", err)
+	}
 
-	router := handler.NewRouter(
-		handler.NewHandler(userUseCase, orderUseCase, balanceUseCase),
+	router, err := handler.NewRouter(
+		handler.NewHandler(authUseCase, userUseCase, orderUseCase, balanceUseCase, healthUseCase, apiSpec),
 		authUseCase,
+		idempotencyUseCase,
 	)
+	if err != nil {
+		return fmt.Errorf("failed to build router: %w", err)
+	}
+
+	janitor := app.NewJanitor(cfg.CleanupInterval)
+	janitor.Add("idempotency keys", idempotencyUseCase.Purge)
+	janitor.Add("expired tokens", authUseCase.PurgeExpiredTokens)
+
+	server := &http.Server{
+		Addr:    cfg.RunAddress,
+		Handler: router,
+	}
 
-	if err = runHTTPServer(ctx, cfg.RunAddress, router); err != nil && !errors.Is(err, http.ErrServerClosed) {
-		logger.Log.Fatal("HTTP server error", zap.Error(err))
+	manager := lifecycle.New()
+	manager.Add(lifecycle.Component{
+		Name: "accrual processor",
+		Start: func(ctx context.Context) error {
+			accrualProcessor.Run(ctx, cfg.UpdateInterval, cfg.WorkerCount)
+			return nil
+		},
+		Stop:        accrualProcessor.Stop,
+		StopTimeout: cfg.AccrualDrainTimeout,
+	})
+	if keySet != nil {
+		manager.Add(lifecycle.Component{
+			Name: "jwt key reloader",
+			Start: func(ctx context.Context) error {
+				reloadJWTKeys(ctx, keySet, cfg.JWTKeysReload)
+				return nil
+			},
+		})
 	}
+	manager.Add(lifecycle.Component{
+		Name: "janitor",
+		Start: func(ctx context.Context) error {
+			janitor.Run(ctx)
+			return nil
+		},
+		Stop: janitor.Stop,
+	})
+	manager.Add(lifecycle.Component{
+		Name: "http server",
+		Start: func(context.Context) error {
+			logger.Log.Info("server is starting", zap.String("address", server.Addr))
+			if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+				return err
+			}
+			return nil
+		},
+		Stop: func(ctx context.Context) error {
+			return shutdownHTTPServer(ctx, server, healthUseCase, cfg.ShutdownDelay)
+		},
+		StopTimeout: cfg.ShutdownDelay + cfg.HTTPDrainTimeout,
+	})
+
+	return manager.Run(ctx)
 }
 
-func runMigrations(db *sql.DB) error {
+func runMigrations(db *sql.DB, command string) error {
 	if err := goose.SetDialect("postgres"); err != nil {
-		return nil
-	}
-	if err := goose.Up(db, "migrations"); err != nil {
 		return err
 	}
 
-	return nil
+	migrationsDir, err := migrations.Extract()
+	if err != nil {
+		return fmt.Errorf("failed to extract migrations: %w", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(migrationsDir); err != nil {
+			logger.Log.Info("failed to remove extracted migrations", zap.Error(err))
+		}
+	}()
+
+	switch command {
+	case "up":
+		return goose.Up(db, migrationsDir)
+	case "down":
+		return goose.Down(db, migrationsDir)
+	case "status":
+		return goose.Status(db, migrationsDir)
+	default:
+		return fmt.Errorf("unknown migrate command %q", command)
+	}
 }
 
-func runHTTPServer(ctx context.Context, address string, handler http.Handler) error {
-	server := &http.Server{
-		Addr:    address,
-		Handler: handler,
+func shutdownHTTPServer(
+	ctx context.Context,
+	server *http.Server,
+	healthUseCase usecase.HealthUseCase,
+	shutdownDelay time.Duration,
+) error {
+	healthUseCase.SetShuttingDown()
+	if shutdownDelay > 0 {
+		logger.Log.Info("reporting not ready before shutdown", zap.Duration("delay", shutdownDelay))
+		select {
+		case <-time.After(shutdownDelay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
 	}
 
-	go func() {
-		<-ctx.Done()
+	logger.Log.Info("server is shutting down...")
+	return server.Shutdown(ctx)
+}
+
+func reloadJWTKeys(ctx context.Context, keySet *keyset.KeySet, interval time.Duration) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
 
-		ctxWithTimeout, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
 
-		logger.Log.Info("server is shutting down...")
-		if err := server.Shutdown(ctxWithTimeout); err != nil {
-			logger.Log.Fatal("server shutdown error", zap.Error(err))
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangup:
+		case <-tick:
 		}
-	}()
 
-	logger.Log.Info("server is starting", zap.String("address", address))
-
-	return server.ListenAndServe()
+		if err := keySet.Reload(); err != nil {
+			logger.Log.Error("failed to reload JWT keys, keeping the current ones", zap.Error(err))
+			continue
+		}
+		logger.Log.Info("JWT keys reloaded", zap.Int("keys", keySet.Len()))
+	}
 }
 
 func loadEnv() {
@@ -0,0 +1,253 @@
+package accrualfake
+
+import (
+	"encoding/json"
+	"github.com/go-chi/chi/v5"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	StatusRegistered = "REGISTERED"
+	StatusProcessing = "PROCESSING"
+	StatusInvalid    = "INVALID"
+	StatusProcessed  = "PROCESSED"
+)
+
+var DefaultSteps = []Step{
+	{Status: StatusRegistered},
+	{Status: StatusProcessing, After: time.Second},
+	{Status: StatusProcessed, After: 2 * time.Second},
+}
+
+var InvalidSteps = []Step{
+	{Status: StatusRegistered},
+	{Status: StatusInvalid, After: time.Second},
+}
+
+type Step struct {
+	Status string
+	After  time.Duration
+}
+
+type Rule struct {
+	Prefix  string
+	Accrual float64
+	Invalid bool
+}
+
+type Options struct {
+	Rules          []Rule
+	DefaultAccrual float64
+	Steps          []Step
+	AutoRegister   bool
+	ErrorRate      float64
+	ThrottleEvery  int
+	RetryAfter     time.Duration
+	Now            func() time.Time
+}
+
+type Response struct {
+	Order   string   `json:"order"`
+	Status  string   `json:"status"`
+	Accrual *float64 `json:"accrual,omitempty"`
+}
+
+type fault struct {
+	statusCode int
+	retryAfter time.Duration
+}
+
+type order struct {
+	registeredAt time.Time
+	accrual      float64
+	steps        []Step
+}
+
+type Server struct {
+	mu       sync.Mutex
+	options  Options
+	orders   map[string]*order
+	faults   []fault
+	requests int
+	router   chi.Router
+	now      func() time.Time
+}
+
+func New(options Options) *Server {
+	if len(options.Steps) == 0 {
+		options.Steps = DefaultSteps
+	}
+	if options.RetryAfter <= 0 {
+		options.RetryAfter = time.Second
+	}
+	if options.Now == nil {
+		options.Now = time.Now
+	}
+
+	s := &Server{
+		options: options,
+		orders:  make(map[string]*order),
+		now:     options.Now,
+	}
+
+	r := chi.NewRouter()
+	r.Get("/api/orders/{number}", s.getOrder)
+	s.router = r
+
+	return s
+}
+
+func NewTestServer(options Options) (*Server, *httptest.Server) {
+	s := New(options)
+	return s, httptest.NewServer(s)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.router.ServeHTTP(w, r)
+}
+
+func (s *Server) Register(number string, accrual float64, steps ...Step) {
+	if len(steps) == 0 {
+		steps = s.options.Steps
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.orders[number] = &order{
+		registeredAt: s.now(),
+		accrual:      accrual,
+		steps:        steps,
+	}
+}
+
+func (s *Server) FailNext(n int, statusCode int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := 0; i < n; i++ {
+		s.faults = append(s.faults, fault{statusCode: statusCode})
+	}
+}
+
+func (s *Server) ThrottleNext(n int, retryAfter time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := 0; i < n; i++ {
+		s.faults = append(s.faults, fault{statusCode: http.StatusTooManyRequests, retryAfter: retryAfter})
+	}
+}
+
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+func (s *Server) getOrder(w http.ResponseWriter, r *http.Request) {
+	number := chi.URLParam(r, "number")
+
+	s.mu.Lock()
+	s.requests++
+	f, faulty := s.nextFault()
+	var response *Response
+	if !faulty {
+		response = s.lookup(number)
+	}
+	s.mu.Unlock()
+
+	if faulty {
+		if f.statusCode == http.StatusTooManyRequests {
+			w.Header().Set("Retry-After", strconv.Itoa(int(f.retryAfter.Seconds())))
+		}
+		w.WriteHeader(f.statusCode)
+		return
+	}
+
+	if response == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+func (s *Server) nextFault() (fault, bool) {
+	if len(s.faults) > 0 {
+		f := s.faults[0]
+		s.faults = s.faults[1:]
+		return f, true
+	}
+
+	if s.options.ThrottleEvery > 0 && s.requests%s.options.ThrottleEvery == 0 {
+		return fault{statusCode: http.StatusTooManyRequests, retryAfter: s.options.RetryAfter}, true
+	}
+
+	if s.options.ErrorRate > 0 && rand.Float64() < s.options.ErrorRate {
+		return fault{statusCode: http.StatusInternalServerError}, true
+	}
+
+	return fault{}, false
+}
+
+func (s *Server) lookup(number string) *Response {
+	o, ok := s.orders[number]
+	if !ok {
+		if !s.options.AutoRegister {
+			return nil
+		}
+		o = s.autoRegister(number)
+	}
+
+	elapsed := s.now().Sub(o.registeredAt)
+	status := ""
+	for _, step := range o.steps {
+		if elapsed >= step.After {
+			status = step.Status
+		}
+	}
+	if status == "" {
+		return nil
+	}
+
+	response := &Response{
+		Order:  number,
+		Status: status,
+	}
+	if status == StatusProcessed {
+		accrual := o.accrual
+		response.Accrual = &accrual
+	}
+
+	return response
+}
+
+func (s *Server) autoRegister(number string) *order {
+	o := &order{
+		registeredAt: s.now(),
+		accrual:      s.options.DefaultAccrual,
+		steps:        s.options.Steps,
+	}
+
+	for _, rule := range s.options.Rules {
+		if !strings.HasPrefix(number, rule.Prefix) {
+			continue
+		}
+		o.accrual = rule.Accrual
+		if rule.Invalid {
+			o.steps = InvalidSteps
+		}
+		break
+	}
+
+	s.orders[number] = o
+	return o
+}
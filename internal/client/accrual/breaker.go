@@ -0,0 +1,148 @@
+package accrual
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrCircuitOpen = errors.New("accrual circuit breaker is open")
+
+type BreakerState int
+
+const (
+	BreakerClosed BreakerState = iota
+	BreakerOpen
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half_open"
+	default:
+		return "unknown"
+	}
+}
+
+type circuitBreaker struct {
+	mu               sync.Mutex
+	state            BreakerState
+	failures         int
+	openedAt         time.Time
+	halfOpenInFlight int
+	threshold        int
+	openTimeout      time.Duration
+	halfOpenMax      int
+	onStateChange    func(from, to BreakerState)
+}
+
+func newCircuitBreaker(threshold int, openTimeout time.Duration, halfOpenMax int, onStateChange func(from, to BreakerState)) *circuitBreaker {
+	if halfOpenMax < 1 {
+		halfOpenMax = 1
+	}
+	return &circuitBreaker{
+		threshold:     threshold,
+		openTimeout:   openTimeout,
+		halfOpenMax:   halfOpenMax,
+		onStateChange: onStateChange,
+	}
+}
+
+func (b *circuitBreaker) Allow() error {
+	if b.threshold <= 0 {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if time.Since(b.openedAt) < b.openTimeout {
+			return ErrCircuitOpen
+		}
+		b.setState(BreakerHalfOpen)
+		b.halfOpenInFlight = 1
+		return nil
+	case BreakerHalfOpen:
+		if b.halfOpenInFlight >= b.halfOpenMax {
+			return ErrCircuitOpen
+		}
+		b.halfOpenInFlight++
+		return nil
+	default:
+		return nil
+	}
+}
+
+func (b *circuitBreaker) Success() {
+	if b.threshold <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures = 0
+	if b.state == BreakerHalfOpen {
+		b.setState(BreakerClosed)
+	}
+}
+
+func (b *circuitBreaker) Failure() {
+	if b.threshold <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerHalfOpen:
+		b.open()
+	case BreakerClosed:
+		b.failures++
+		if b.failures >= b.threshold {
+			b.open()
+		}
+	}
+}
+
+func (b *circuitBreaker) Release() {
+	if b.threshold <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerHalfOpen && b.halfOpenInFlight > 0 {
+		b.halfOpenInFlight--
+	}
+}
+
+func (b *circuitBreaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+func (b *circuitBreaker) open() {
+	b.openedAt = time.Now()
+	b.failures = 0
+	b.setState(BreakerOpen)
+}
+
+func (b *circuitBreaker) setState(state BreakerState) {
+	from := b.state
+	b.state = state
+	b.halfOpenInFlight = 0
+	if from != state && b.onStateChange != nil {
+		b.onStateChange(from, state)
+	}
+}
@@ -4,25 +4,124 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"github.com/invinciblewest/gophermart/internal/logger"
+	"github.com/invinciblewest/gophermart/internal/metrics"
 	"github.com/invinciblewest/gophermart/internal/model"
+	"github.com/invinciblewest/gophermart/internal/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"io"
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
+const (
+	tracerName        = "github.com/invinciblewest/gophermart/internal/client/accrual"
+	pingCacheTTL      = 5 * time.Second
+	defaultRetryAfter = 60
+)
+
+var ErrNotChecked = errors.New("accrual service not checked yet: rate limited")
+
+type Options struct {
+	Timeout                 time.Duration
+	RateLimit               float64
+	RateBurst               int
+	BreakerThreshold        int
+	BreakerOpenTimeout      time.Duration
+	BreakerHalfOpenRequests int
+	OnBreakerStateChange    func(from, to BreakerState)
+}
+
+type BreakerStats struct {
+	State      string
+	Opened     uint64
+	HalfOpened uint64
+	Closed     uint64
+	Rejected   uint64
+}
+
 type Client struct {
-	client  *http.Client
-	baseURL string
+	client      *http.Client
+	baseURL     string
+	limiter     *rateLimiter
+	breaker     *circuitBreaker
+	transitions [3]atomic.Uint64
+	rejected    atomic.Uint64
+
+	pingMu  sync.Mutex
+	pingAt  time.Time
+	pingErr error
 }
 
-func NewClient(baseURL string) *Client {
-	return &Client{
-		client:  http.DefaultClient,
+func NewClient(baseURL string, options Options) *Client {
+	c := &Client{
+		client: &http.Client{
+			Timeout: options.Timeout,
+		},
 		baseURL: baseURL,
+		limiter: newRateLimiter(options.RateLimit, options.RateBurst),
+	}
+
+	c.breaker = newCircuitBreaker(
+		options.BreakerThreshold,
+		options.BreakerOpenTimeout,
+		options.BreakerHalfOpenRequests,
+		func(from, to BreakerState) {
+			c.transitions[to].Add(1)
+			metrics.AccrualBreakerState.Set(float64(to))
+			metrics.AccrualBreakerTransitions.WithLabelValues(to.String()).Inc()
+			logger.Log.Warn("accrual circuit breaker state changed",
+				zap.String("from", from.String()), zap.String("to", to.String()))
+			if options.OnBreakerStateChange != nil {
+				options.OnBreakerStateChange(from, to)
+			}
+		},
+	)
+
+	return c
+}
+
+func (c *Client) BreakerStats() BreakerStats {
+	return BreakerStats{
+		State:      c.breaker.State().String(),
+		Opened:     c.transitions[BreakerOpen].Load(),
+		HalfOpened: c.transitions[BreakerHalfOpen].Load(),
+		Closed:     c.transitions[BreakerClosed].Load(),
+		Rejected:   c.rejected.Load(),
 	}
 }
 
 func (c *Client) GetOrderInfo(ctx context.Context, orderNumber string) (*model.AccrualResponse, int, error) {
+	ctx, span := otel.Tracer(tracerName).Start(ctx, "accrual.GetOrderInfo",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("order.number", orderNumber)))
+	defer span.End()
+
+	start := time.Now()
+	response, retryAfter, err := c.getOrderInfo(ctx, orderNumber)
+
+	outcome := requestOutcome(response, retryAfter, err)
+	metrics.AccrualRequests.WithLabelValues(outcome).Inc()
+	metrics.AccrualRequestDuration.WithLabelValues(outcome).Observe(time.Since(start).Seconds())
+
+	span.SetAttributes(attribute.String("accrual.outcome", outcome))
+	tracing.RecordError(span, err)
+
+	return response, retryAfter, err
+}
+
+func (c *Client) getOrderInfo(ctx context.Context, orderNumber string) (*model.AccrualResponse, int, error) {
 	path, err := url.JoinPath(c.baseURL, "api", "orders", orderNumber)
 	if err != nil {
 		return nil, 0, err
@@ -31,36 +130,151 @@ func (c *Client) GetOrderInfo(ctx context.Context, orderNumber string) (*model.A
 	if err != nil {
 		return nil, 0, err
 	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(request.Header))
+
+	if err = c.limiter.Wait(ctx); err != nil {
+		return nil, 0, err
+	}
+
+	if err = c.breaker.Allow(); err != nil {
+		c.rejected.Add(1)
+		return nil, 0, err
+	}
 
 	response, err := c.client.Do(request)
 	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			c.breaker.Release()
+		} else {
+			c.breaker.Failure()
+		}
 		return nil, 0, err
 	}
 	defer response.Body.Close()
+	trace.SpanFromContext(ctx).SetAttributes(semconv.HTTPResponseStatusCode(response.StatusCode))
+
+	if response.StatusCode >= http.StatusInternalServerError {
+		c.breaker.Failure()
+		return nil, 0, errors.New("failed to get order info")
+	}
 
 	if response.StatusCode == http.StatusTooManyRequests {
-		retryAfterStr := response.Header.Get("Retry-After")
-		seconds, err := strconv.Atoi(retryAfterStr)
+		c.breaker.Release()
+		seconds, err := parseRetryAfter(response.Header.Get("Retry-After"))
 		if err != nil {
-			return nil, 0, err
+			logger.FromContext(ctx).Info("invalid Retry-After header, using the default backoff",
+				zap.String("retry_after", response.Header.Get("Retry-After")), zap.Error(err))
+			seconds = defaultRetryAfter
 		}
+		c.limiter.PauseUntil(time.Now().Add(time.Duration(seconds) * time.Second))
 
 		return nil, seconds, nil
 	}
 
+	c.breaker.Success()
+
 	if response.StatusCode == http.StatusNoContent {
 		return nil, 0, nil
 	}
 
 	if response.StatusCode != http.StatusOK {
-
 		return nil, 0, errors.New("failed to get order info")
 	}
 
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, 0, err
+	}
+
 	var accrualResponse *model.AccrualResponse
-	if err = json.NewDecoder(response.Body).Decode(&accrualResponse); err != nil {
+	if err = json.Unmarshal(body, &accrualResponse); err != nil {
 		return nil, 0, err
 	}
+	if accrualResponse == nil {
+		return nil, 0, errors.New("accrual service returned an empty order")
+	}
+	accrualResponse.Raw = body
 
 	return accrualResponse, 0, nil
 }
+
+func (c *Client) Ping(ctx context.Context) error {
+	if state := c.breaker.State(); state == BreakerOpen {
+		return ErrCircuitOpen
+	}
+
+	c.pingMu.Lock()
+	defer c.pingMu.Unlock()
+
+	if !c.pingAt.IsZero() && time.Since(c.pingAt) < pingCacheTTL {
+		return c.pingErr
+	}
+	if !c.limiter.Allow() {
+		if c.pingAt.IsZero() {
+			return ErrNotChecked
+		}
+		return c.pingErr
+	}
+
+	err := c.ping(ctx)
+	if ctx.Err() != nil {
+		return err
+	}
+	c.pingAt = time.Now()
+	c.pingErr = err
+	return err
+}
+
+func (c *Client) ping(ctx context.Context) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL, nil)
+	if err != nil {
+		return err
+	}
+
+	response, err := c.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("accrual service responded with %d", response.StatusCode)
+	}
+
+	return nil
+}
+
+func (c *Client) BreakerState() BreakerState {
+	return c.breaker.State()
+}
+
+func requestOutcome(response *model.AccrualResponse, retryAfter int, err error) string {
+	switch {
+	case errors.Is(err, ErrCircuitOpen):
+		return "circuit_open"
+	case err != nil:
+		return "error"
+	case retryAfter > 0:
+		return "throttled"
+	case response == nil:
+		return "not_registered"
+	}
+	if _, ok := response.Status.OrderStatus(); !ok {
+		return "unknown_status"
+	}
+	return strings.ToLower(string(response.Status))
+}
+
+func parseRetryAfter(value string) (int, error) {
+	seconds, err := strconv.Atoi(value)
+	if err == nil {
+		return max(seconds, 1), nil
+	}
+
+	date, dateErr := http.ParseTime(value)
+	if dateErr != nil {
+		return 0, err
+	}
+
+	return max(int(time.Until(date).Seconds()), 1), nil
+}
@@ -0,0 +1,217 @@
+package accrual
+
+import (
+	"context"
+	"errors"
+	"github.com/invinciblewest/gophermart/internal/accrualfake"
+	"github.com/invinciblewest/gophermart/internal/model"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestClient(t *testing.T, fakeOptions accrualfake.Options, options Options) (*accrualfake.Server, *accrualfake.ManualClock, *Client) {
+	t.Helper()
+
+	clock := accrualfake.NewManualClock(time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC))
+	fakeOptions.Now = clock.Now
+	fake, server := accrualfake.NewTestServer(fakeOptions)
+	t.Cleanup(server.Close)
+
+	if options.Timeout == 0 {
+		options.Timeout = time.Second
+	}
+	return fake, clock, NewClient(server.URL, options)
+}
+
+func TestGetOrderInfoFollowsOrderSteps(t *testing.T) {
+	fake, clock, client := newTestClient(t, accrualfake.Options{}, Options{})
+	fake.Register("12345678903", 729.98)
+
+	tests := []struct {
+		advance time.Duration
+		status  model.AccrualStatus
+		accrual model.Amount
+	}{
+		{advance: 0, status: model.AccrualStatusRegistered},
+		{advance: time.Second, status: model.AccrualStatusProcessing},
+		{advance: time.Second, status: model.AccrualStatusProcessed, accrual: 72998},
+	}
+
+	for _, tt := range tests {
+		clock.Advance(tt.advance)
+
+		response, retryAfter, err := client.GetOrderInfo(context.Background(), "12345678903")
+		if err != nil {
+			t.Fatalf("GetOrderInfo() error = %v", err)
+		}
+		if retryAfter != 0 {
+			t.Fatalf("GetOrderInfo() retryAfter = %d, want 0", retryAfter)
+		}
+		if response == nil {
+			t.Fatalf("GetOrderInfo() response = nil, want status %s", tt.status)
+		}
+		if response.Status != tt.status || response.Accrual != tt.accrual {
+			t.Errorf("GetOrderInfo() = %s/%d, want %s/%d", response.Status, response.Accrual, tt.status, tt.accrual)
+		}
+		if len(response.Raw) == 0 {
+			t.Error("GetOrderInfo() did not keep the raw response")
+		}
+	}
+}
+
+func TestGetOrderInfoUnregisteredOrder(t *testing.T) {
+	_, _, client := newTestClient(t, accrualfake.Options{}, Options{})
+
+	response, retryAfter, err := client.GetOrderInfo(context.Background(), "12345678903")
+	if err != nil || retryAfter != 0 || response != nil {
+		t.Errorf("GetOrderInfo() = %v, %d, %v, want nil, 0, nil", response, retryAfter, err)
+	}
+}
+
+func TestGetOrderInfoNullBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte("null"))
+	}))
+	t.Cleanup(server.Close)
+	client := NewClient(server.URL, Options{Timeout: time.Second})
+
+	response, _, err := client.GetOrderInfo(context.Background(), "12345678903")
+	if err == nil || response != nil {
+		t.Errorf("GetOrderInfo() = %v, %v, want an error", response, err)
+	}
+}
+
+func TestGetOrderInfoThrottledDoesNotTripBreaker(t *testing.T) {
+	fake, _, client := newTestClient(t, accrualfake.Options{AutoRegister: true}, Options{BreakerThreshold: 1})
+	fake.ThrottleNext(1, 3*time.Second)
+
+	_, retryAfter, err := client.GetOrderInfo(context.Background(), "12345678903")
+	if err != nil {
+		t.Fatalf("GetOrderInfo() error = %v", err)
+	}
+	if retryAfter != 3 {
+		t.Errorf("GetOrderInfo() retryAfter = %d, want 3", retryAfter)
+	}
+	if state := client.BreakerState(); state != BreakerClosed {
+		t.Errorf("BreakerState() = %s, want closed", state)
+	}
+}
+
+func TestGetOrderInfoThrottledWithoutRetryAfterUsesDefault(t *testing.T) {
+	var requests atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	t.Cleanup(server.Close)
+	client := NewClient(server.URL, Options{Timeout: time.Second})
+
+	_, retryAfter, err := client.GetOrderInfo(context.Background(), "12345678903")
+	if err != nil {
+		t.Fatalf("GetOrderInfo() error = %v", err)
+	}
+	if retryAfter != defaultRetryAfter {
+		t.Errorf("GetOrderInfo() retryAfter = %d, want %d", retryAfter, defaultRetryAfter)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, _, err = client.GetOrderInfo(ctx, "12345678903"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("GetOrderInfo() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if requests.Load() != 1 {
+		t.Errorf("accrual service got %d requests, want 1", requests.Load())
+	}
+}
+
+func TestGetOrderInfoOpensBreakerAfterFailures(t *testing.T) {
+	fake, _, client := newTestClient(t, accrualfake.Options{AutoRegister: true}, Options{
+		BreakerThreshold:   2,
+		BreakerOpenTimeout: time.Hour,
+	})
+	fake.FailNext(2, http.StatusInternalServerError)
+
+	for i := 0; i < 2; i++ {
+		if _, _, err := client.GetOrderInfo(context.Background(), "12345678903"); err == nil {
+			t.Fatalf("GetOrderInfo() call %d error = nil, want failure", i+1)
+		}
+	}
+
+	requests := fake.Requests()
+	if _, _, err := client.GetOrderInfo(context.Background(), "12345678903"); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("GetOrderInfo() error = %v, want %v", err, ErrCircuitOpen)
+	}
+	if fake.Requests() != requests {
+		t.Error("GetOrderInfo() reached the accrual service while the breaker was open")
+	}
+}
+
+func TestGetOrderInfoLimiterTimeoutDoesNotTripBreaker(t *testing.T) {
+	fake, _, client := newTestClient(t, accrualfake.Options{AutoRegister: true}, Options{
+		RateLimit:        0.001,
+		RateBurst:        1,
+		BreakerThreshold: 1,
+	})
+
+	if _, _, err := client.GetOrderInfo(context.Background(), "12345678903"); err != nil {
+		t.Fatalf("GetOrderInfo() error = %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, _, err := client.GetOrderInfo(ctx, "12345678903"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("GetOrderInfo() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	if state := client.BreakerState(); state != BreakerClosed {
+		t.Errorf("BreakerState() = %s, want closed", state)
+	}
+	if fake.Requests() != 1 {
+		t.Errorf("accrual service got %d requests, want 1", fake.Requests())
+	}
+}
+
+func newCountingServer(t *testing.T) (*atomic.Int64, string) {
+	t.Helper()
+
+	var requests atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(server.Close)
+	return &requests, server.URL
+}
+
+func TestPingReusesRecentResult(t *testing.T) {
+	requests, serverURL := newCountingServer(t)
+	client := NewClient(serverURL, Options{Timeout: time.Second})
+
+	for i := 0; i < 3; i++ {
+		if err := client.Ping(context.Background()); err != nil {
+			t.Fatalf("Ping() error = %v", err)
+		}
+	}
+	if requests.Load() != 1 {
+		t.Errorf("accrual service got %d requests, want 1", requests.Load())
+	}
+}
+
+func TestPingRespectsRateLimit(t *testing.T) {
+	requests, serverURL := newCountingServer(t)
+	client := NewClient(serverURL, Options{Timeout: time.Second, RateLimit: 0.001, RateBurst: 1})
+
+	if _, _, err := client.GetOrderInfo(context.Background(), "12345678903"); err != nil {
+		t.Fatalf("GetOrderInfo() error = %v", err)
+	}
+	if err := client.Ping(context.Background()); !errors.Is(err, ErrNotChecked) {
+		t.Fatalf("Ping() error = %v, want %v", err, ErrNotChecked)
+	}
+	if requests.Load() != 1 {
+		t.Errorf("accrual service got %d requests, want 1", requests.Load())
+	}
+}
@@ -0,0 +1,87 @@
+package accrual
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+type rateLimiter struct {
+	mu          sync.Mutex
+	rate        float64
+	burst       float64
+	tokens      float64
+	last        time.Time
+	pausedUntil time.Time
+}
+
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+func (l *rateLimiter) Wait(ctx context.Context) error {
+	for {
+		wait, ok := l.reserve(time.Now())
+		if ok {
+			return nil
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (l *rateLimiter) Allow() bool {
+	_, ok := l.reserve(time.Now())
+	return ok
+}
+
+func (l *rateLimiter) PauseUntil(until time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if until.After(l.pausedUntil) {
+		l.pausedUntil = until
+		l.tokens = 0
+		l.last = until
+	}
+}
+
+func (l *rateLimiter) reserve(now time.Time) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Before(l.pausedUntil) {
+		return l.pausedUntil.Sub(now), false
+	}
+
+	if l.rate <= 0 {
+		return 0, true
+	}
+
+	if now.After(l.last) {
+		l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+		l.last = now
+	}
+
+	if l.tokens >= 1 {
+		l.tokens--
+		return 0, true
+	}
+
+	return time.Duration((1 - l.tokens) / l.rate * float64(time.Second)), false
+}
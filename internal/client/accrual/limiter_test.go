@@ -0,0 +1,28 @@
+package accrual
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiterDoesNotRefillDuringPause(t *testing.T) {
+	start := time.Now()
+	limiter := newRateLimiter(1, 5)
+	limiter.last = start
+
+	until := start.Add(10 * time.Second)
+	limiter.PauseUntil(until)
+
+	if _, ok := limiter.reserve(until.Add(-time.Second)); ok {
+		t.Error("reserve() allowed a request while paused")
+	}
+	if _, ok := limiter.reserve(until); ok {
+		t.Error("reserve() allowed a request right after the pause with no tokens refilled")
+	}
+	if _, ok := limiter.reserve(until.Add(time.Second)); !ok {
+		t.Error("reserve() denied a request after a token was refilled")
+	}
+	if _, ok := limiter.reserve(until.Add(time.Second)); ok {
+		t.Error("reserve() allowed a burst accumulated during the pause")
+	}
+}
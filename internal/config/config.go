@@ -3,16 +3,45 @@ package config
 import (
 	"flag"
 	"github.com/caarlos0/env/v6"
+	"time"
 )
 
 type Config struct {
-	RunAddress           string `env:"RUN_ADDRESS"`
-	DatabaseURL          string `env:"DATABASE_URI"`
-	AccrualSystemAddress string `env:"ACCRUAL_SYSTEM_ADDRESS"`
-	LogLevel             string `env:"LOG_LEVEL"`
-	SecretKey            string `env:"SECRET_KEY"`
-	UpdateInterval       int    `env:"UPDATE_INTERVAL"`
-	WorkerCount          int    `env:"WORKER_COUNT"`
+	RunAddress           string        `env:"RUN_ADDRESS"`
+	DatabaseURL          string        `env:"DATABASE_URI"`
+	AccrualSystemAddress string        `env:"ACCRUAL_SYSTEM_ADDRESS"`
+	LogLevel             string        `env:"LOG_LEVEL"`
+	SecretKey            string        `env:"SECRET_KEY"`
+	UpdateInterval       int           `env:"UPDATE_INTERVAL"`
+	WorkerCount          int           `env:"WORKER_COUNT"`
+	AccessTokenTTL       time.Duration `env:"ACCESS_TOKEN_TTL"`
+	RefreshTokenTTL      time.Duration `env:"REFRESH_TOKEN_TTL"`
+	OrderBatchMaxSize    int           `env:"ORDER_BATCH_MAX_SIZE"`
+	OrderNumberRules     string        `env:"ORDER_NUMBER_RULES"`
+	AccrualTimeout       time.Duration `env:"ACCRUAL_TIMEOUT"`
+	AccrualRateLimit     float64       `env:"ACCRUAL_RATE_LIMIT"`
+	AccrualRateBurst     int           `env:"ACCRUAL_RATE_BURST"`
+	BreakerThreshold     int           `env:"ACCRUAL_BREAKER_THRESHOLD"`
+	BreakerOpenTimeout   time.Duration `env:"ACCRUAL_BREAKER_OPEN_TIMEOUT"`
+	BreakerHalfOpenMax   int           `env:"ACCRUAL_BREAKER_HALF_OPEN_REQUESTS"`
+	AccrualBatchSize     int           `env:"ACCRUAL_BATCH_SIZE"`
+	AccrualJobLease      time.Duration `env:"ACCRUAL_JOB_LEASE"`
+	AccrualBackoffBase   time.Duration `env:"ACCRUAL_BACKOFF_BASE"`
+	AccrualBackoffMax    time.Duration `env:"ACCRUAL_BACKOFF_MAX"`
+	AccrualMaxAge        time.Duration `env:"ACCRUAL_MAX_AGE"`
+	JWTKeysDir           string        `env:"JWT_KEYS_DIR"`
+	JWTSigningKeyID      string        `env:"JWT_SIGNING_KEY_ID"`
+	JWTKeysReload        time.Duration `env:"JWT_KEYS_RELOAD_INTERVAL"`
+	TraceExporter        string        `env:"TRACE_EXPORTER"`
+	TraceFile            string        `env:"TRACE_FILE"`
+	TraceSampleRatio     float64       `env:"TRACE_SAMPLE_RATIO"`
+	ShutdownDelay        time.Duration `env:"SHUTDOWN_DELAY"`
+	HTTPDrainTimeout     time.Duration `env:"HTTP_DRAIN_TIMEOUT"`
+	AccrualDrainTimeout  time.Duration `env:"ACCRUAL_DRAIN_TIMEOUT"`
+	SkipMigrations       bool          `env:"SKIP_MIGRATIONS"`
+	IdempotencyLease     time.Duration `env:"IDEMPOTENCY_LEASE"`
+	IdempotencyKeyTTL    time.Duration `env:"IDEMPOTENCY_KEY_TTL"`
+	CleanupInterval      time.Duration `env:"CLEANUP_INTERVAL"`
 }
 
 func GetConfig() (Config, error) {
@@ -25,6 +54,35 @@ func GetConfig() (Config, error) {
 	flag.StringVar(&config.SecretKey, "s", "", "secret key")
 	flag.IntVar(&config.UpdateInterval, "i", 10, "update interval in seconds")
 	flag.IntVar(&config.WorkerCount, "w", 5, "number of workers")
+	flag.IntVar(&config.OrderBatchMaxSize, "batch-max", 100, "max number of orders in a batch upload")
+	flag.StringVar(&config.OrderNumberRules, "order-rules", `[{"storefront":"default","rules":[{"type":"luhn"}]}]`,
+		`accepted order number formats per storefront as JSON, e.g. [{"storefront":"eu","rules":[{"type":"regex","pattern":"^EU\\d+$"},{"type":"length","min":8,"max":12}]}]; rule types are luhn, length and regex`)
+	flag.DurationVar(&config.AccrualTimeout, "accrual-timeout", 5*time.Second, "accrual request timeout")
+	flag.Float64Var(&config.AccrualRateLimit, "accrual-rps", 50, "accrual requests per second, 0 disables the limit")
+	flag.IntVar(&config.AccrualRateBurst, "accrual-burst", 10, "accrual request burst size")
+	flag.IntVar(&config.BreakerThreshold, "breaker-threshold", 5, "consecutive accrual failures that open the breaker, 0 disables it")
+	flag.DurationVar(&config.BreakerOpenTimeout, "breaker-open-timeout", 30*time.Second, "time the breaker stays open before probing")
+	flag.IntVar(&config.BreakerHalfOpenMax, "breaker-half-open", 1, "probe requests allowed while the breaker is half-open")
+	flag.IntVar(&config.AccrualBatchSize, "b", 100, "max number of accrual jobs claimed per tick")
+	flag.DurationVar(&config.AccrualJobLease, "lease", time.Minute, "accrual job lease duration")
+	flag.DurationVar(&config.AccrualBackoffBase, "backoff-base", 5*time.Second, "initial accrual retry delay")
+	flag.DurationVar(&config.AccrualBackoffMax, "backoff-max", 10*time.Minute, "maximum accrual retry delay")
+	flag.DurationVar(&config.AccrualMaxAge, "max-age", 72*time.Hour, "age after which unprocessed orders are marked invalid")
+	flag.DurationVar(&config.AccessTokenTTL, "access-ttl", 15*time.Minute, "access token lifetime")
+	flag.DurationVar(&config.RefreshTokenTTL, "refresh-ttl", 30*24*time.Hour, "refresh token lifetime")
+	flag.StringVar(&config.JWTKeysDir, "jwt-keys", "", "directory with PEM keys for JWT signing")
+	flag.StringVar(&config.JWTSigningKeyID, "jwt-kid", "", "id of the key used to sign JWTs")
+	flag.DurationVar(&config.JWTKeysReload, "jwt-keys-reload", 0, "interval between JWT key reloads, 0 reloads only on SIGHUP")
+	flag.StringVar(&config.TraceExporter, "trace-exporter", "none", "trace exporter: none, stdout, file or otlp")
+	flag.StringVar(&config.TraceFile, "trace-file", "traces.jsonl", "output file for the file trace exporter")
+	flag.Float64Var(&config.TraceSampleRatio, "trace-sample", 1, "fraction of new traces to sample")
+	flag.DurationVar(&config.ShutdownDelay, "shutdown-delay", 0, "time to report not ready before the server stops accepting connections")
+	flag.DurationVar(&config.HTTPDrainTimeout, "http-drain-timeout", 10*time.Second, "time to wait for in-flight requests on shutdown")
+	flag.DurationVar(&config.AccrualDrainTimeout, "accrual-drain-timeout", 30*time.Second, "time to wait for accrual workers to finish on shutdown")
+	flag.DurationVar(&config.IdempotencyLease, "idempotency-lease", time.Minute, "time after which an unfinished idempotent request can be retried")
+	flag.DurationVar(&config.IdempotencyKeyTTL, "idempotency-ttl", 24*time.Hour, "time idempotency keys are kept")
+	flag.DurationVar(&config.CleanupInterval, "cleanup-interval", time.Hour, "interval between purges of expired keys and tokens")
+	flag.BoolVar(&config.SkipMigrations, "skip-migrations", false, "do not apply migrations on startup")
 
 	flag.Parse()
 
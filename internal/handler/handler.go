@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/go-chi/chi/v5"
 	"github.com/invinciblewest/gophermart/internal/helper"
 	"github.com/invinciblewest/gophermart/internal/logger"
 	"github.com/invinciblewest/gophermart/internal/model"
@@ -15,116 +17,132 @@ import (
 )
 
 type Handler struct {
+	AuthUseCase    usecase.AuthUseCase
 	UserUseCase    usecase.UserUseCase
 	OrderUseCase   usecase.OrderUseCase
 	BalanceUseCase usecase.BalanceUseCase
+	HealthUseCase  usecase.HealthUseCase
+	APISpec        *openapi3.T
 }
 
 func NewHandler(
+	authUseCase usecase.AuthUseCase,
 	userUseCase usecase.UserUseCase,
 	orderUseCase usecase.OrderUseCase,
 	balanceUseCase usecase.BalanceUseCase,
+	healthUseCase usecase.HealthUseCase,
+	apiSpec *openapi3.T,
 ) *Handler {
 	return &Handler{
+		AuthUseCase:    authUseCase,
 		UserUseCase:    userUseCase,
 		OrderUseCase:   orderUseCase,
 		BalanceUseCase: balanceUseCase,
+		HealthUseCase:  healthUseCase,
+		APISpec:        apiSpec,
 	}
 }
 
 func (h *Handler) RegisterUser(w http.ResponseWriter, r *http.Request) {
-	if !strings.Contains(r.Header.Get("Content-Type"), "application/json") {
-		w.WriteHeader(http.StatusBadRequest)
+	var user model.User
+	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
+		helper.WriteError(w, r, fmt.Errorf("%w: %w", model.ErrMalformedRequest, err))
+		return
+	}
+
+	tokens, err := h.UserUseCase.RegisterAndLogin(r.Context(), &user)
+	if err != nil {
+		helper.WriteError(w, r, err)
 		return
 	}
 
+	h.writeTokens(w, r, tokens)
+}
+
+func (h *Handler) LoginUser(w http.ResponseWriter, r *http.Request) {
 	var user model.User
 	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		helper.WriteError(w, r, fmt.Errorf("%w: %w", model.ErrMalformedRequest, err))
 		return
 	}
-
-	token, err := h.UserUseCase.RegisterAndLogin(r.Context(), &user)
+	tokens, err := h.UserUseCase.Login(r.Context(), user)
 	if err != nil {
-		switch {
-		case errors.Is(err, model.ErrEmptyLoginOrPassword):
-			w.WriteHeader(http.StatusBadRequest)
-			return
-		case errors.Is(err, model.ErrUserAlreadyExists):
-			w.WriteHeader(http.StatusConflict)
-			return
-		default:
-			w.WriteHeader(http.StatusInternalServerError)
-			logger.Log.Info("failed to register user", zap.Error(err))
-			return
+		if errors.Is(err, model.ErrUserNotFound) || errors.Is(err, model.ErrInvalidPassword) {
+			logger.FromContext(r.Context()).Info("failed login attempt", zap.String("login", user.Login), zap.Error(err))
 		}
+		helper.WriteError(w, r, err)
+		return
 	}
 
-	w.Header().Set("Authorization", fmt.Sprintf("Bearer %s", token))
-	w.WriteHeader(http.StatusOK)
+	h.writeTokens(w, r, tokens)
 }
 
-func (h *Handler) LoginUser(w http.ResponseWriter, r *http.Request) {
-	if !strings.Contains(r.Header.Get("Content-Type"), "application/json") {
-		w.WriteHeader(http.StatusBadRequest)
+func (h *Handler) RefreshToken(w http.ResponseWriter, r *http.Request) {
+	var request model.RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		helper.WriteError(w, r, fmt.Errorf("%w: %w", model.ErrMalformedRequest, err))
+		return
+	}
+	if request.RefreshToken == "" {
+		helper.WriteError(w, r, fmt.Errorf("%w: refresh_token is required", model.ErrMalformedRequest))
 		return
 	}
 
-	var user model.User
-	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+	tokens, err := h.AuthUseCase.RefreshTokens(r.Context(), request.RefreshToken)
+	if err != nil {
+		helper.WriteError(w, r, err)
 		return
 	}
-	token, err := h.UserUseCase.Login(r.Context(), user)
+
+	h.writeTokens(w, r, tokens)
+}
+
+func (h *Handler) LogoutUser(w http.ResponseWriter, r *http.Request) {
+	claims, err := helper.GetTokenClaims(r)
 	if err != nil {
-		switch {
-		case errors.Is(err, model.ErrEmptyLoginOrPassword):
-			w.WriteHeader(http.StatusBadRequest)
-			return
-		case errors.Is(err, model.ErrUserNotFound):
-			logger.Log.Info("user not found", zap.String("login", user.Login))
-			w.WriteHeader(http.StatusUnauthorized)
-			return
-		case errors.Is(err, model.ErrInvalidPassword):
-			logger.Log.Info("invalid password", zap.String("login", user.Login))
-			w.WriteHeader(http.StatusUnauthorized)
-			return
-		default:
-			w.WriteHeader(http.StatusInternalServerError)
-			logger.Log.Info("failed to login user", zap.Error(err))
+		helper.WriteError(w, r, err)
+		return
+	}
+
+	var request model.RefreshRequest
+	if strings.Contains(r.Header.Get("Content-Type"), "application/json") {
+		if err = json.NewDecoder(r.Body).Decode(&request); err != nil && !errors.Is(err, io.EOF) {
+			helper.WriteError(w, r, fmt.Errorf("%w: %w", model.ErrMalformedRequest, err))
 			return
 		}
 	}
 
-	w.Header().Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	if err = h.AuthUseCase.RevokeTokens(r.Context(), claims, request.RefreshToken); err != nil {
+		if errors.Is(err, model.ErrInvalidToken) {
+			err = fmt.Errorf("%w: %w", model.ErrMalformedRequest, err)
+		}
+		helper.WriteError(w, r, err)
+		return
+	}
+
 	w.WriteHeader(http.StatusOK)
 }
 
 func (h *Handler) AddOrder(w http.ResponseWriter, r *http.Request) {
 	userID, err := helper.GetUserID(r)
 	if err != nil {
-		w.WriteHeader(http.StatusUnauthorized)
-		return
-	}
-
-	if r.Header.Get("Content-Type") != "text/plain" {
-		w.WriteHeader(http.StatusBadRequest)
+		helper.WriteError(w, r, err)
 		return
 	}
 
 	orderNumber, err := io.ReadAll(r.Body)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		helper.WriteError(w, r, fmt.Errorf("%w: %w", model.ErrMalformedRequest, err))
 		return
 	}
 	defer func(Body io.ReadCloser) {
 		if err = Body.Close(); err != nil {
-			logger.Log.Error("failed to close request body", zap.Error(err))
+			logger.FromContext(r.Context()).Error("failed to close request body", zap.Error(err))
 		}
 	}(r.Body)
 
 	if len(orderNumber) == 0 {
-		w.WriteHeader(http.StatusBadRequest)
+		helper.WriteError(w, r, fmt.Errorf("%w: order number is empty", model.ErrMalformedRequest))
 		return
 	}
 
@@ -134,48 +152,151 @@ func (h *Handler) AddOrder(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err = h.OrderUseCase.AddOrder(r.Context(), &order); err != nil {
-		switch {
-		case errors.Is(err, model.ErrInvalidOrderNumber):
-			w.WriteHeader(http.StatusUnprocessableEntity)
-			return
-		case errors.Is(err, model.ErrOrderAlreadyExists):
+		if errors.Is(err, model.ErrOrderAlreadyExists) {
 			w.WriteHeader(http.StatusOK)
 			return
-		case errors.Is(err, model.ErrOrderAlreadyExistsForAnotherUser):
-			w.WriteHeader(http.StatusConflict)
+		}
+		helper.WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (h *Handler) AddOrders(w http.ResponseWriter, r *http.Request) {
+	userID, err := helper.GetUserID(r)
+	if err != nil {
+		helper.WriteError(w, r, err)
+		return
+	}
+
+	var numbers []string
+	switch contentType := r.Header.Get("Content-Type"); {
+	case strings.Contains(contentType, "application/json"):
+		if err = json.NewDecoder(r.Body).Decode(&numbers); err != nil {
+			helper.WriteError(w, r, batchBodyError(err))
 			return
-		default:
-			w.WriteHeader(http.StatusInternalServerError)
-			logger.Log.Info("failed to add order", zap.Error(err))
+		}
+	case strings.Contains(contentType, "text/plain"):
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			helper.WriteError(w, r, batchBodyError(err))
 			return
 		}
+		for _, line := range strings.Split(string(body), "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				numbers = append(numbers, line)
+			}
+		}
+	default:
+		helper.WriteError(w, r, model.ErrInvalidContentType)
+		return
 	}
 
-	w.WriteHeader(http.StatusAccepted)
+	results, err := h.OrderUseCase.AddOrders(r.Context(), userID, numbers)
+	if err != nil {
+		helper.WriteError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err = json.NewEncoder(w).Encode(results); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		logger.FromContext(r.Context()).Info("failed to encode order batch results", zap.Error(err))
+		return
+	}
+}
+
+func batchBodyError(err error) error {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return model.ErrOrderBatchTooLarge
+	}
+	return fmt.Errorf("%w: %w", model.ErrMalformedRequest, err)
 }
 
 func (h *Handler) GetUserOrders(w http.ResponseWriter, r *http.Request) {
 	userID, err := helper.GetUserID(r)
 	if err != nil {
-		w.WriteHeader(http.StatusUnauthorized)
+		helper.WriteError(w, r, err)
 		return
 	}
 
-	orders, err := h.OrderUseCase.GetByUser(r.Context(), userID)
+	query, err := parseListQuery(r, "uploaded_at", true)
+	if err != nil {
+		helper.WriteError(w, r, err)
+		return
+	}
+
+	orders, next, err := h.OrderUseCase.GetByUser(r.Context(), userID, query)
 	if err != nil {
 		if errors.Is(err, model.ErrOrderNotFound) {
 			w.WriteHeader(http.StatusNoContent)
 			return
 		}
-		w.WriteHeader(http.StatusInternalServerError)
-		logger.Log.Info("failed to get orders", zap.Error(err))
+		helper.WriteError(w, r, err)
 		return
 	}
 
+	setNextCursor(w, r, next)
 	w.Header().Set("Content-Type", "application/json")
 	if err = json.NewEncoder(w).Encode(orders); err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
-		logger.Log.Info("failed to encode orders", zap.Error(err))
+		logger.FromContext(r.Context()).Info("failed to encode orders", zap.Error(err))
+		return
+	}
+}
+
+func (h *Handler) GetOrder(w http.ResponseWriter, r *http.Request) {
+	userID, err := helper.GetUserID(r)
+	if err != nil {
+		helper.WriteError(w, r, err)
+		return
+	}
+
+	order, err := h.OrderUseCase.GetOrder(r.Context(), userID, chi.URLParam(r, "number"))
+	if err != nil {
+		helper.WriteError(w, r, err)
+		return
+	}
+
+	body, err := json.Marshal(order)
+	if err != nil {
+		helper.WriteError(w, r, fmt.Errorf("failed to encode order: %w", err))
+		return
+	}
+
+	etag := helper.ETag(body)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "private, no-cache")
+	if helper.MatchesETag(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if _, err = w.Write(body); err != nil {
+		logger.FromContext(r.Context()).Info("failed to write order", zap.Error(err))
+	}
+}
+
+func (h *Handler) GetOrderHistory(w http.ResponseWriter, r *http.Request) {
+	userID, err := helper.GetUserID(r)
+	if err != nil {
+		helper.WriteError(w, r, err)
+		return
+	}
+
+	events, err := h.OrderUseCase.GetOrderHistory(r.Context(), userID, chi.URLParam(r, "number"))
+	if err != nil {
+		helper.WriteError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err = json.NewEncoder(w).Encode(events); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		logger.FromContext(r.Context()).Info("failed to encode order history", zap.Error(err))
 		return
 	}
 }
@@ -183,20 +304,20 @@ func (h *Handler) GetUserOrders(w http.ResponseWriter, r *http.Request) {
 func (h *Handler) GetUserBalance(w http.ResponseWriter, r *http.Request) {
 	userID, err := helper.GetUserID(r)
 	if err != nil {
-		w.WriteHeader(http.StatusUnauthorized)
+		helper.WriteError(w, r, err)
 		return
 	}
 
 	balance, err := h.BalanceUseCase.GetUserBalance(r.Context(), userID)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		helper.WriteError(w, r, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	if err = json.NewEncoder(w).Encode(balance); err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
-		logger.Log.Info("failed to encode balance", zap.Error(err))
+		logger.FromContext(r.Context()).Info("failed to encode balance", zap.Error(err))
 		return
 	}
 }
@@ -204,34 +325,19 @@ func (h *Handler) GetUserBalance(w http.ResponseWriter, r *http.Request) {
 func (h *Handler) WithdrawBalance(w http.ResponseWriter, r *http.Request) {
 	userID, err := helper.GetUserID(r)
 	if err != nil {
-		w.WriteHeader(http.StatusUnauthorized)
-		return
-	}
-
-	if !strings.Contains(r.Header.Get("Content-Type"), "application/json") {
-		w.WriteHeader(http.StatusBadRequest)
+		helper.WriteError(w, r, err)
 		return
 	}
 
 	var withdrawRequest model.WithdrawRequest
 	if err = json.NewDecoder(r.Body).Decode(&withdrawRequest); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		helper.WriteError(w, r, fmt.Errorf("%w: %w", model.ErrMalformedRequest, err))
 		return
 	}
 
 	if err = h.BalanceUseCase.WithdrawBalance(r.Context(), userID, withdrawRequest); err != nil {
-		switch {
-		case errors.Is(err, model.ErrInvalidWithdrawSum):
-			w.WriteHeader(http.StatusPaymentRequired)
-			return
-		case errors.Is(err, model.ErrInvalidOrderNumber):
-			w.WriteHeader(http.StatusUnprocessableEntity)
-			return
-		default:
-			w.WriteHeader(http.StatusInternalServerError)
-			logger.Log.Info("failed to withdraw balance", zap.Error(err))
-			return
-		}
+		helper.WriteError(w, r, err)
+		return
 	}
 
 }
@@ -239,25 +345,86 @@ func (h *Handler) WithdrawBalance(w http.ResponseWriter, r *http.Request) {
 func (h *Handler) GetWithdrawals(w http.ResponseWriter, r *http.Request) {
 	userID, err := helper.GetUserID(r)
 	if err != nil {
-		w.WriteHeader(http.StatusUnauthorized)
+		helper.WriteError(w, r, err)
 		return
 	}
 
-	withdrawals, err := h.BalanceUseCase.GetWithdrawals(r.Context(), userID)
+	query, err := parseListQuery(r, "processed_at", false)
+	if err != nil {
+		helper.WriteError(w, r, err)
+		return
+	}
+
+	withdrawals, next, err := h.BalanceUseCase.GetWithdrawals(r.Context(), userID, query)
 	if err != nil {
 		if errors.Is(err, model.ErrWithdrawalNotFound) {
 			w.WriteHeader(http.StatusNoContent)
 			return
 		}
-		w.WriteHeader(http.StatusInternalServerError)
-		logger.Log.Info("failed to get withdrawals", zap.Error(err))
+		helper.WriteError(w, r, err)
 		return
 	}
 
+	setNextCursor(w, r, next)
 	w.Header().Set("Content-Type", "application/json")
 	if err = json.NewEncoder(w).Encode(withdrawals); err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
-		logger.Log.Info("failed to encode withdrawals", zap.Error(err))
+		logger.FromContext(r.Context()).Info("failed to encode withdrawals", zap.Error(err))
+		return
+	}
+}
+
+func (h *Handler) writeTokens(w http.ResponseWriter, r *http.Request, tokens *model.TokenPair) {
+	w.Header().Set("Authorization", fmt.Sprintf("Bearer %s", tokens.AccessToken))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(tokens); err != nil {
+		logger.FromContext(r.Context()).Info("failed to encode tokens", zap.Error(err))
+	}
+}
+
+func (h *Handler) GetJWKS(w http.ResponseWriter, r *http.Request) {
+	body, err := json.Marshal(h.AuthUseCase.JWKS())
+	if err != nil {
+		helper.WriteError(w, r, fmt.Errorf("failed to encode jwks: %w", err))
 		return
 	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	if _, err = w.Write(body); err != nil {
+		logger.FromContext(r.Context()).Info("failed to write jwks", zap.Error(err))
+	}
+}
+
+func (h *Handler) GetOpenAPISpec(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(h.APISpec); err != nil {
+		logger.FromContext(r.Context()).Info("failed to encode openapi spec", zap.Error(err))
+	}
+}
+
+func (h *Handler) Livez(w http.ResponseWriter, r *http.Request) {
+	h.writeHealth(w, r, h.HealthUseCase.Live())
+}
+
+func (h *Handler) Readyz(w http.ResponseWriter, r *http.Request) {
+	report := h.HealthUseCase.Ready(r.Context())
+	report.Checks = nil
+	h.writeHealth(w, r, report)
+}
+
+func (h *Handler) Healthz(w http.ResponseWriter, r *http.Request) {
+	h.writeHealth(w, r, h.HealthUseCase.Ready(r.Context()))
+}
+
+func (h *Handler) writeHealth(w http.ResponseWriter, r *http.Request, report model.HealthReport) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if report.Status != model.HealthStatusOK {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		logger.FromContext(r.Context()).Info("failed to encode health report", zap.Error(err))
+	}
 }
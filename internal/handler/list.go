@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"fmt"
+	"github.com/invinciblewest/gophermart/internal/model"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 1000
+)
+
+func parseListQuery(r *http.Request, sortField string, withStatus bool) (model.ListQuery, error) {
+	values := r.URL.Query()
+	var query model.ListQuery
+
+	if limit := values.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 || n > maxPageSize {
+			return query, model.ErrInvalidListQuery
+		}
+		query.Limit = n
+	}
+
+	if cursor := values.Get("cursor"); cursor != "" {
+		decoded, err := model.DecodeCursor(cursor)
+		if err != nil {
+			return query, err
+		}
+		query.Cursor = decoded
+		if query.Limit == 0 {
+			query.Limit = defaultPageSize
+		}
+	}
+
+	switch values.Get("sort") {
+	case "", "-" + sortField:
+	case sortField:
+		query.Ascending = true
+	default:
+		return query, model.ErrInvalidListQuery
+	}
+
+	if !withStatus && values.Has("status") {
+		return query, model.ErrInvalidListQuery
+	}
+
+	for _, value := range values["status"] {
+		for _, status := range strings.Split(value, ",") {
+			switch orderStatus := model.OrderStatus(strings.ToUpper(strings.TrimSpace(status))); orderStatus {
+			case model.OrderStatusNew, model.OrderStatusProcessing, model.OrderStatusInvalid, model.OrderStatusProcessed:
+				query.Statuses = append(query.Statuses, orderStatus)
+			default:
+				return query, model.ErrInvalidListQuery
+			}
+		}
+	}
+
+	for name, target := range map[string]**time.Time{"from": &query.From, "to": &query.To} {
+		value := values.Get(name)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return query, model.ErrInvalidListQuery
+		}
+		*target = &t
+	}
+
+	return query, nil
+}
+
+func setNextCursor(w http.ResponseWriter, r *http.Request, next string) {
+	if next == "" {
+		return
+	}
+
+	u := *r.URL
+	values := u.Query()
+	values.Set("cursor", next)
+	u.RawQuery = values.Encode()
+
+	w.Header().Set("X-Next-Cursor", next)
+	w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, u.RequestURI()))
+}
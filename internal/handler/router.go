@@ -3,32 +3,65 @@ package handler
 import (
 	"github.com/go-chi/chi/v5"
 	chiMiddleware "github.com/go-chi/chi/v5/middleware"
+	"github.com/invinciblewest/gophermart/internal/metrics"
 	customMiddleware "github.com/invinciblewest/gophermart/internal/middleware"
+	"github.com/invinciblewest/gophermart/internal/model"
 	"github.com/invinciblewest/gophermart/internal/usecase"
 )
 
-func NewRouter(h *Handler, authUseCase usecase.AuthUseCase) *chi.Mux {
+const maxOrderBatchBodySize = 1 << 20
+
+func NewRouter(
+	h *Handler,
+	authUseCase usecase.AuthUseCase,
+	idempotencyUseCase usecase.IdempotencyUseCase,
+) (*chi.Mux, error) {
+	validate, err := customMiddleware.ValidationMiddleware(h.APISpec)
+	if err != nil {
+		return nil, err
+	}
+
 	r := chi.NewRouter()
 
+	r.Use(customMiddleware.RequestIDMiddleware)
+	r.Use(customMiddleware.TracingMiddleware)
 	r.Use(chiMiddleware.Recoverer)
 	r.Use(customMiddleware.LoggerMiddleware)
+	r.Use(customMiddleware.MetricsMiddleware)
 	r.Use(chiMiddleware.Compress(5))
 
+	r.Get("/livez", h.Livez)
+	r.Get("/readyz", h.Readyz)
+	r.Get("/healthz", h.Healthz)
+	r.Handle("/metrics", metrics.Handler())
+	r.Get("/.well-known/jwks.json", h.GetJWKS)
+
 	r.Route("/api", func(r chi.Router) {
+		r.Get("/openapi.json", h.GetOpenAPISpec)
+
 		r.Route("/user", func(r chi.Router) {
-			r.Post("/register", h.RegisterUser)
-			r.Post("/login", h.LoginUser)
+			idempotent := customMiddleware.IdempotencyMiddleware(idempotencyUseCase)
+
+			validated := r.With(customMiddleware.RouteLoggerMiddleware, validate)
+			validated.With(idempotent).Post("/register", h.RegisterUser)
+			validated.Post("/login", h.LoginUser)
+			validated.Post("/token/refresh", h.RefreshToken)
 
-			withAuth := r.With(customMiddleware.AuthMiddleware(authUseCase))
-			withAuth.Post("/orders", h.AddOrder)
+			authenticate := customMiddleware.AuthMiddleware(authUseCase)
+			withAuth := r.With(customMiddleware.RouteLoggerMiddleware, authenticate, validate)
+			limitBatch := customMiddleware.BodyLimitMiddleware(maxOrderBatchBodySize, model.ErrOrderBatchTooLarge)
+			withAuth.With(idempotent).Post("/orders", h.AddOrder)
+			r.With(customMiddleware.RouteLoggerMiddleware, limitBatch, authenticate, validate, idempotent).
+				Post("/orders/batch", h.AddOrders)
 			withAuth.Get("/orders", h.GetUserOrders)
-			withAuth.Route("/balance", func(withAuth chi.Router) {
-				withAuth.Get("/", h.GetUserBalance)
-				withAuth.Post("/withdraw", h.WithdrawBalance)
-			})
+			withAuth.Get("/orders/{number}", h.GetOrder)
+			withAuth.Get("/orders/{number}/history", h.GetOrderHistory)
+			withAuth.Get("/balance", h.GetUserBalance)
+			withAuth.With(idempotent).Post("/balance/withdraw", h.WithdrawBalance)
 			withAuth.Get("/withdrawals", h.GetWithdrawals)
+			withAuth.Post("/logout", h.LogoutUser)
 		})
 	})
 
-	return r
+	return r, nil
 }
@@ -0,0 +1,249 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers/legacy"
+	"github.com/invinciblewest/gophermart/internal/helper"
+	"github.com/invinciblewest/gophermart/internal/model"
+	"github.com/invinciblewest/gophermart/internal/openapi"
+	"github.com/invinciblewest/gophermart/internal/usecase"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testAccessToken = "valid-token"
+
+type fakeAuthUseCase struct {
+	usecase.AuthUseCase
+}
+
+func (fakeAuthUseCase) ParseToken(_ context.Context, token string) (*model.TokenClaims, error) {
+	if token != testAccessToken {
+		return nil, model.ErrInvalidToken
+	}
+	return &model.TokenClaims{ID: "token", UserID: 1, FamilyID: "family", ExpiresAt: time.Now().Add(time.Hour)}, nil
+}
+
+type fakeUserUseCase struct {
+	usecase.UserUseCase
+}
+
+func (fakeUserUseCase) RegisterAndLogin(_ context.Context, user *model.User) (*model.TokenPair, error) {
+	if user.Login == "taken" {
+		return nil, model.ErrUserAlreadyExists
+	}
+	return &model.TokenPair{AccessToken: "access", RefreshToken: "refresh", TokenType: "Bearer", ExpiresIn: 900}, nil
+}
+
+func (fakeUserUseCase) Login(context.Context, model.User) (*model.TokenPair, error) {
+	return nil, model.ErrInvalidPassword
+}
+
+type fakeOrderUseCase struct {
+	usecase.OrderUseCase
+}
+
+func (fakeOrderUseCase) GetByUser(context.Context, int, model.ListQuery) ([]model.Order, string, error) {
+	accrual := model.Amount(50000)
+	return []model.Order{
+		{Number: "12345678903", Status: model.OrderStatusProcessed, Accrual: &accrual, UploadedAt: time.Now()},
+		{Number: "9278923470", Status: model.OrderStatusNew, UploadedAt: time.Now()},
+	}, "", nil
+}
+
+func (fakeOrderUseCase) GetOrder(_ context.Context, _ int, number string) (*model.Order, error) {
+	return nil, model.ErrOrderNotFound
+}
+
+type fakeBalanceUseCase struct {
+	usecase.BalanceUseCase
+}
+
+func (fakeBalanceUseCase) GetUserBalance(context.Context, int) (*model.Balance, error) {
+	return &model.Balance{Current: 50050, Withdrawn: 4200}, nil
+}
+
+func (fakeBalanceUseCase) WithdrawBalance(context.Context, int, model.WithdrawRequest) error {
+	return model.ErrInvalidWithdrawSum
+}
+
+type fakeHealthUseCase struct {
+	usecase.HealthUseCase
+}
+
+func (fakeHealthUseCase) Live() model.HealthReport {
+	return model.HealthReport{Status: model.HealthStatusOK}
+}
+
+func newContractRouter(t *testing.T) http.Handler {
+	t.Helper()
+
+	spec, err := openapi.Load()
+	if err != nil {
+		t.Fatalf("openapi.Load() error = %v", err)
+	}
+	h := NewHandler(fakeAuthUseCase{}, fakeUserUseCase{}, fakeOrderUseCase{}, fakeBalanceUseCase{}, fakeHealthUseCase{}, spec)
+	router, err := NewRouter(h, fakeAuthUseCase{}, nil)
+	if err != nil {
+		t.Fatalf("NewRouter() error = %v", err)
+	}
+	return router
+}
+
+func TestRouterMatchesOpenAPIContract(t *testing.T) {
+	router := newContractRouter(t)
+
+	spec, err := openapi.Load()
+	if err != nil {
+		t.Fatalf("openapi.Load() error = %v", err)
+	}
+	specRouter, err := legacy.NewRouter(spec)
+	if err != nil {
+		t.Fatalf("legacy.NewRouter() error = %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		contentType string
+		body        string
+		token       string
+		status      int
+		problemCode string
+	}{
+		{name: "register", method: http.MethodPost, path: "/api/user/register", contentType: "application/json",
+			body: `{"login":"user","password":"secret"}`, status: http.StatusOK},
+		{name: "register with a malformed body", method: http.MethodPost, path: "/api/user/register", contentType: "application/json",
+			body: `{"login":42}`, status: http.StatusBadRequest, problemCode: "malformed_request"},
+		{name: "register a taken login", method: http.MethodPost, path: "/api/user/register", contentType: "application/json",
+			body: `{"login":"taken","password":"secret"}`, status: http.StatusConflict, problemCode: "login_taken"},
+		{name: "login with a wrong password", method: http.MethodPost, path: "/api/user/login", contentType: "application/json",
+			body: `{"login":"user","password":"wrong"}`, status: http.StatusUnauthorized, problemCode: "invalid_credentials"},
+		{name: "balance without a token", method: http.MethodGet, path: "/api/user/balance",
+			status: http.StatusUnauthorized, problemCode: "unauthorized"},
+		{name: "balance with an invalid token", method: http.MethodGet, path: "/api/user/balance", token: "expired",
+			status: http.StatusUnauthorized, problemCode: "invalid_token"},
+		{name: "balance", method: http.MethodGet, path: "/api/user/balance", token: testAccessToken, status: http.StatusOK},
+		{name: "orders", method: http.MethodGet, path: "/api/user/orders", token: testAccessToken, status: http.StatusOK},
+		{name: "orders with an invalid limit", method: http.MethodGet, path: "/api/user/orders?limit=0", token: testAccessToken,
+			status: http.StatusBadRequest, problemCode: "malformed_request"},
+		{name: "unknown order", method: http.MethodGet, path: "/api/user/orders/12345678903", token: testAccessToken,
+			status: http.StatusNotFound, problemCode: "order_not_found"},
+		{name: "order with a wrong content type", method: http.MethodPost, path: "/api/user/orders", token: testAccessToken,
+			contentType: "application/json", body: `"12345678903"`, status: http.StatusBadRequest, problemCode: "invalid_content_type"},
+		{name: "withdraw with a malformed body", method: http.MethodPost, path: "/api/user/balance/withdraw", token: testAccessToken,
+			contentType: "application/json", body: `{"order":"2377225624","sum":"many"}`, status: http.StatusBadRequest, problemCode: "malformed_request"},
+		{name: "withdraw more than the balance", method: http.MethodPost, path: "/api/user/balance/withdraw", token: testAccessToken,
+			contentType: "application/json", body: `{"order":"2377225624","sum":751}`, status: http.StatusPaymentRequired, problemCode: "insufficient_funds"},
+		{name: "liveness", method: http.MethodGet, path: "/livez", status: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				request.Header.Set("Content-Type", tt.contentType)
+			}
+			if tt.token != "" {
+				request.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, request)
+
+			response := recorder.Result()
+			body, _ := io.ReadAll(response.Body)
+			if response.StatusCode != tt.status {
+				t.Fatalf("status = %d, want %d, body %s", response.StatusCode, tt.status, body)
+			}
+
+			specRequest := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			specRequest.Header = request.Header
+			route, pathParams, err := specRouter.FindRoute(specRequest)
+			if err != nil {
+				t.Fatalf("FindRoute() error = %v", err)
+			}
+			err = openapi3filter.ValidateResponse(context.Background(), &openapi3filter.ResponseValidationInput{
+				RequestValidationInput: &openapi3filter.RequestValidationInput{
+					Request:    specRequest,
+					PathParams: pathParams,
+					Route:      route,
+				},
+				Status: response.StatusCode,
+				Header: response.Header,
+				Body:   io.NopCloser(bytes.NewReader(body)),
+				Options: &openapi3filter.Options{
+					IncludeResponseStatus: true,
+				},
+			})
+			if err != nil {
+				t.Errorf("response does not match the spec: %v", err)
+			}
+
+			if tt.problemCode == "" {
+				return
+			}
+			if contentType := response.Header.Get("Content-Type"); contentType != helper.ProblemContentType {
+				t.Errorf("Content-Type = %q, want %q", contentType, helper.ProblemContentType)
+			}
+			var problem model.Problem
+			if err = json.Unmarshal(body, &problem); err != nil {
+				t.Fatalf("failed to decode problem: %v", err)
+			}
+			if problem.Code != tt.problemCode || problem.Status != tt.status {
+				t.Errorf("problem = %s/%d, want %s/%d", problem.Code, problem.Status, tt.problemCode, tt.status)
+			}
+		})
+	}
+}
+
+func TestRouterRejectsTrailingSlash(t *testing.T) {
+	router := newContractRouter(t)
+
+	request := httptest.NewRequest(http.MethodGet, "/api/user/balance/", nil)
+	request.Header.Set("Authorization", "Bearer "+testAccessToken)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want %d", recorder.Code, http.StatusNotFound)
+	}
+}
+
+func TestRouterRejectsOversizedBatch(t *testing.T) {
+	router := newContractRouter(t)
+
+	tests := []struct {
+		name          string
+		body          io.Reader
+		contentLength int64
+	}{
+		{name: "declared length", body: strings.NewReader(strings.Repeat("12345678903\n", maxOrderBatchBodySize/12+1))},
+		{name: "chunked body", body: io.MultiReader(strings.NewReader(strings.Repeat("12345678903\n", maxOrderBatchBodySize/12+1))), contentLength: -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodPost, "/api/user/orders/batch", tt.body)
+			if tt.contentLength != 0 {
+				request.ContentLength = tt.contentLength
+			}
+			request.Header.Set("Content-Type", "text/plain")
+			request.Header.Set("Authorization", "Bearer "+testAccessToken)
+			request.Header.Set("Idempotency-Key", "batch")
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, request)
+
+			if recorder.Code != http.StatusRequestEntityTooLarge {
+				t.Errorf("status = %d, want %d, body %s", recorder.Code, http.StatusRequestEntityTooLarge, recorder.Body)
+			}
+		})
+	}
+}
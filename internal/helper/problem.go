@@ -0,0 +1,96 @@
+package helper
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/invinciblewest/gophermart/internal/logger"
+	"github.com/invinciblewest/gophermart/internal/model"
+	"go.uber.org/zap"
+	"net/http"
+)
+
+const (
+	ProblemContentType = "application/problem+json"
+	problemTypePrefix  = "urn:gophermart:problem:"
+)
+
+type problemType struct {
+	err        error
+	status     int
+	code       string
+	title      string
+	hideDetail bool
+}
+
+var problemTypes = []problemType{
+	{err: model.ErrMalformedRequest, status: http.StatusBadRequest, code: "malformed_request", title: "Malformed request"},
+	{err: model.ErrInvalidContentType, status: http.StatusBadRequest, code: "invalid_content_type", title: "Unsupported content type"},
+	{err: model.ErrEmptyLoginOrPassword, status: http.StatusBadRequest, code: "empty_credentials", title: "Login or password is empty"},
+	{err: model.ErrUserAlreadyExists, status: http.StatusConflict, code: "login_taken", title: "Login is already taken"},
+	{err: model.ErrUserNotFound, status: http.StatusUnauthorized, code: "invalid_credentials", title: "Invalid login or password", hideDetail: true},
+	{err: model.ErrInvalidPassword, status: http.StatusUnauthorized, code: "invalid_credentials", title: "Invalid login or password", hideDetail: true},
+	{err: model.ErrUserDisabled, status: http.StatusForbidden, code: "user_disabled", title: "User is disabled"},
+	{err: model.ErrInvalidToken, status: http.StatusUnauthorized, code: "invalid_token", title: "Invalid token"},
+	{err: model.ErrTokenRevoked, status: http.StatusUnauthorized, code: "token_revoked", title: "Token has been revoked"},
+	{err: model.ErrRefreshTokenReused, status: http.StatusUnauthorized, code: "refresh_token_reused", title: "Refresh token has already been used"},
+	{err: model.ErrUnauthorized, status: http.StatusUnauthorized, code: "unauthorized", title: "Authentication required"},
+	{err: model.ErrInvalidOrderNumber, status: http.StatusUnprocessableEntity, code: "invalid_order_number", title: "Invalid order number"},
+	{err: model.ErrOrderAlreadyExistsForAnotherUser, status: http.StatusConflict, code: "order_owned_by_another_user", title: "Order was uploaded by another user"},
+	{err: model.ErrOrderAlreadyExists, status: http.StatusConflict, code: "order_already_exists", title: "Order already exists"},
+	{err: model.ErrOrderNotFound, status: http.StatusNotFound, code: "order_not_found", title: "Order not found"},
+	{err: model.ErrEmptyOrderBatch, status: http.StatusBadRequest, code: "empty_order_batch", title: "Order batch is empty"},
+	{err: model.ErrOrderBatchTooLarge, status: http.StatusRequestEntityTooLarge, code: "order_batch_too_large", title: "Order batch is too large"},
+	{err: model.ErrInvalidWithdrawAmount, status: http.StatusUnprocessableEntity, code: "invalid_withdraw_amount", title: "Withdrawal sum must be positive"},
+	{err: model.ErrInvalidWithdrawSum, status: http.StatusPaymentRequired, code: "insufficient_funds", title: "Insufficient funds for withdrawal"},
+	{err: model.ErrWithdrawalNotFound, status: http.StatusNotFound, code: "withdrawal_not_found", title: "Withdrawal not found"},
+	{err: model.ErrInvalidListQuery, status: http.StatusBadRequest, code: "invalid_list_query", title: "Invalid list query"},
+	{err: model.ErrInvalidIdempotencyKey, status: http.StatusBadRequest, code: "invalid_idempotency_key", title: "Invalid idempotency key"},
+	{err: model.ErrIdempotencyKeyReused, status: http.StatusUnprocessableEntity, code: "idempotency_key_reused", title: "Idempotency key was used for a different request"},
+	{err: model.ErrIdempotencyKeyInProgress, status: http.StatusConflict, code: "idempotency_key_in_progress", title: "Request with this idempotency key is in progress"},
+}
+
+var internalProblem = problemType{
+	status:     http.StatusInternalServerError,
+	code:       "internal_error",
+	title:      "Internal server error",
+	hideDetail: true,
+}
+
+func NewProblem(r *http.Request, err error) model.Problem {
+	pt := internalProblem
+	for _, candidate := range problemTypes {
+		if errors.Is(err, candidate.err) {
+			pt = candidate
+			break
+		}
+	}
+
+	problem := model.Problem{
+		Type:      problemTypePrefix + pt.code,
+		Title:     pt.title,
+		Status:    pt.status,
+		Code:      pt.code,
+		Instance:  r.URL.Path,
+		RequestID: middleware.GetReqID(r.Context()),
+	}
+	if !pt.hideDetail && err != nil {
+		problem.Detail = err.Error()
+	}
+
+	return problem
+}
+
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	problem := NewProblem(r, err)
+	if problem.Status >= http.StatusInternalServerError {
+		logger.FromContext(r.Context()).Info("request failed", zap.Error(err))
+	}
+
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(problem.Status)
+	if err = json.NewEncoder(w).Encode(problem); err != nil {
+		logger.FromContext(r.Context()).Info("failed to encode problem", zap.Error(err))
+	}
+}
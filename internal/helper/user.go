@@ -1,18 +1,29 @@
 package helper
 
 import (
-	"errors"
+	"github.com/invinciblewest/gophermart/internal/model"
 	"net/http"
 )
 
 type ContextKey string
 
-const UserIDKey ContextKey = "user_id"
+const (
+	UserIDKey      ContextKey = "user_id"
+	TokenClaimsKey ContextKey = "token_claims"
+)
 
 func GetUserID(r *http.Request) (int, error) {
 	userID, ok := r.Context().Value(UserIDKey).(int)
 	if !ok {
-		return 0, errors.New("user ID not found in context")
+		return 0, model.ErrUnauthorized
 	}
 	return userID, nil
 }
+
+func GetTokenClaims(r *http.Request) (*model.TokenClaims, error) {
+	claims, ok := r.Context().Value(TokenClaimsKey).(*model.TokenClaims)
+	if !ok {
+		return nil, model.ErrUnauthorized
+	}
+	return claims, nil
+}
@@ -0,0 +1,209 @@
+package keyset
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/invinciblewest/gophermart/internal/model"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+type Key struct {
+	ID        string
+	Algorithm string
+	Private   crypto.Signer
+	Public    crypto.PublicKey
+}
+
+type KeySet struct {
+	dir          string
+	signingKeyID string
+	state        atomic.Pointer[keyState]
+}
+
+type keyState struct {
+	keys         map[string]*Key
+	signingKeyID string
+}
+
+func Load(dir, signingKeyID string) (*KeySet, error) {
+	ks := &KeySet{
+		dir:          dir,
+		signingKeyID: signingKeyID,
+	}
+	if err := ks.Reload(); err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
+
+func (ks *KeySet) Reload() error {
+	state, err := loadKeys(ks.dir, ks.signingKeyID)
+	if err != nil {
+		return err
+	}
+	ks.state.Store(state)
+	return nil
+}
+
+func loadKeys(dir, signingKeyID string) (*keyState, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	ks := &keyState{
+		keys: make(map[string]*Key),
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".pem" {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		key, err := parseKey(strings.TrimSuffix(entry.Name(), ".pem"), data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load key %s: %w", entry.Name(), err)
+		}
+		ks.keys[key.ID] = key
+	}
+
+	if signingKeyID == "" {
+		for id, key := range ks.keys {
+			if key.Private == nil {
+				continue
+			}
+			if signingKeyID != "" {
+				return nil, errors.New("several private keys found, signing key id must be set")
+			}
+			signingKeyID = id
+		}
+	}
+
+	signingKey, ok := ks.keys[signingKeyID]
+	if !ok || signingKey.Private == nil {
+		return nil, fmt.Errorf("private key %q not found in %s", signingKeyID, dir)
+	}
+	ks.signingKeyID = signingKeyID
+
+	return ks, nil
+}
+
+func (ks *KeySet) Len() int {
+	return len(ks.state.Load().keys)
+}
+
+func (ks *KeySet) SigningKey() *Key {
+	state := ks.state.Load()
+	return state.keys[state.signingKeyID]
+}
+
+func (ks *KeySet) VerificationKey(kid string) (*Key, bool) {
+	key, ok := ks.state.Load().keys[kid]
+	return key, ok
+}
+
+func (ks *KeySet) Algorithms() []string {
+	seen := make(map[string]bool)
+	var algorithms []string
+	for _, key := range ks.state.Load().keys {
+		if !seen[key.Algorithm] {
+			seen[key.Algorithm] = true
+			algorithms = append(algorithms, key.Algorithm)
+		}
+	}
+	sort.Strings(algorithms)
+	return algorithms
+}
+
+func (ks *KeySet) JWKS() model.JWKS {
+	keys := ks.state.Load().keys
+	ids := make([]string, 0, len(keys))
+	for id := range keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	jwks := model.JWKS{Keys: make([]model.JWK, 0, len(ids))}
+	for _, id := range ids {
+		key := keys[id]
+		jwk := model.JWK{
+			KeyID:     key.ID,
+			Use:       "sig",
+			Algorithm: key.Algorithm,
+		}
+
+		switch public := key.Public.(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		}
+
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+
+	return jwks
+}
+
+func parseKey(id string, data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var parsed any
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key := &Key{ID: id}
+	if signer, ok := parsed.(crypto.Signer); ok {
+		key.Private = signer
+		key.Public = signer.Public()
+	} else {
+		key.Public = parsed
+	}
+
+	switch key.Public.(type) {
+	case *rsa.PublicKey:
+		key.Algorithm = jwt.SigningMethodRS256.Alg()
+	case ed25519.PublicKey:
+		key.Algorithm = jwt.SigningMethodEdDSA.Alg()
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", key.Public)
+	}
+
+	return key, nil
+}
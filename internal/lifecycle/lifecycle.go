@@ -0,0 +1,125 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/invinciblewest/gophermart/internal/logger"
+	"go.uber.org/zap"
+	"time"
+)
+
+const defaultStopTimeout = 10 * time.Second
+
+type Component struct {
+	Name        string
+	Start       func(ctx context.Context) error
+	Stop        func(ctx context.Context) error
+	StopTimeout time.Duration
+}
+
+type Manager struct {
+	components []Component
+}
+
+type exitResult struct {
+	index int
+	err   error
+}
+
+func New() *Manager {
+	return &Manager{}
+}
+
+func (m *Manager) Add(component Component) {
+	m.components = append(m.components, component)
+}
+
+func (m *Manager) Run(ctx context.Context) error {
+	results := make(chan exitResult, len(m.components))
+	running := make([]bool, len(m.components))
+	cancels := make([]context.CancelFunc, len(m.components))
+
+	for i, component := range m.components {
+		if component.Start == nil {
+			continue
+		}
+
+		componentCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		cancels[i] = cancel
+		running[i] = true
+
+		go func() {
+			results <- exitResult{index: i, err: component.Start(componentCtx)}
+		}()
+		logger.Log.Info("component started", zap.String("component", component.Name))
+	}
+
+	var errs []error
+	select {
+	case <-ctx.Done():
+		logger.Log.Info("shutdown signal received")
+	case result := <-results:
+		running[result.index] = false
+		name := m.components[result.index].Name
+		if result.err == nil {
+			result.err = errors.New("exited without being stopped")
+		}
+		logger.Log.Error("component failed, shutting down", zap.String("component", name), zap.Error(result.err))
+		errs = append(errs, fmt.Errorf("%s: %w", name, result.err))
+	}
+
+	for i := len(m.components) - 1; i >= 0; i-- {
+		errs = append(errs, m.stop(ctx, i, results, running, cancels[i]))
+		if cancels[i] != nil {
+			cancels[i]()
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (m *Manager) stop(
+	ctx context.Context,
+	index int,
+	results <-chan exitResult,
+	running []bool,
+	cancel context.CancelFunc,
+) error {
+	component := m.components[index]
+
+	timeout := component.StopTimeout
+	if timeout <= 0 {
+		timeout = defaultStopTimeout
+	}
+	stopCtx, stopCancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+	defer stopCancel()
+
+	logger.Log.Info("stopping component", zap.String("component", component.Name), zap.Duration("timeout", timeout))
+
+	var errs []error
+	if component.Stop != nil {
+		if err := component.Stop(stopCtx); err != nil {
+			errs = append(errs, fmt.Errorf("failed to stop %s: %w", component.Name, err))
+		}
+	} else if cancel != nil {
+		cancel()
+	}
+
+	for running[index] {
+		select {
+		case result := <-results:
+			running[result.index] = false
+			if result.err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", m.components[result.index].Name, result.err))
+			}
+		case <-stopCtx.Done():
+			errs = append(errs, fmt.Errorf("%s did not stop within %s", component.Name, timeout))
+			logger.Log.Error("component did not stop in time", zap.String("component", component.Name))
+			return errors.Join(errs...)
+		}
+	}
+
+	logger.Log.Info("component stopped", zap.String("component", component.Name))
+	return errors.Join(errs...)
+}
@@ -1,6 +1,20 @@
 package logger
 
-import "go.uber.org/zap"
+import (
+	"context"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"sync/atomic"
+)
+
+type (
+	contextKey      struct{}
+	requestScopeKey struct{}
+)
+
+type requestScope struct {
+	logger atomic.Pointer[zap.Logger]
+}
 
 var Log = zap.NewNop()
 
@@ -11,7 +25,9 @@ func Initialize(level string) error {
 	}
 	cfg := zap.NewProductionConfig()
 	cfg.Level = lvl
-	zl, err := cfg.Build()
+	zl, err := cfg.Build(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return &redactingCore{Core: core}
+	}))
 	if err != nil {
 		return err
 	}
@@ -19,3 +35,38 @@ func Initialize(level string) error {
 	Log = zl
 	return nil
 }
+
+func FromContext(ctx context.Context) *zap.Logger {
+	if l, ok := ctx.Value(contextKey{}).(*zap.Logger); ok {
+		return l
+	}
+	return Log
+}
+
+func WithContext(ctx context.Context, l *zap.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+func With(ctx context.Context, fields ...zap.Field) context.Context {
+	return WithContext(ctx, FromContext(ctx).With(fields...))
+}
+
+func WithRequestScope(ctx context.Context, l *zap.Logger) context.Context {
+	scope := &requestScope{}
+	scope.logger.Store(l)
+	return WithContext(context.WithValue(ctx, requestScopeKey{}, scope), l)
+}
+
+func RequestLogger(ctx context.Context) *zap.Logger {
+	if scope, ok := ctx.Value(requestScopeKey{}).(*requestScope); ok {
+		return scope.logger.Load()
+	}
+	return FromContext(ctx)
+}
+
+func Annotate(ctx context.Context, fields ...zap.Field) context.Context {
+	if scope, ok := ctx.Value(requestScopeKey{}).(*requestScope); ok {
+		scope.logger.Store(scope.logger.Load().With(fields...))
+	}
+	return With(ctx, fields...)
+}
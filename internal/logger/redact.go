@@ -0,0 +1,73 @@
+package logger
+
+import (
+	"go.uber.org/zap/zapcore"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+var (
+	sensitiveKeys = []string{"password", "token", "secret", "authorization", "cookie"}
+	dsnPassword   = regexp.MustCompile(`(?i)(password\s*=\s*)('[^']*'|\S+)`)
+)
+
+type redactingCore struct {
+	zapcore.Core
+}
+
+func (c *redactingCore) With(fields []zapcore.Field) zapcore.Core {
+	return &redactingCore{Core: c.Core.With(redactFields(fields))}
+}
+
+func (c *redactingCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Core.Check(entry, nil) == nil {
+		return checked
+	}
+	return checked.AddCore(entry, c)
+}
+
+func (c *redactingCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	return c.Core.Write(entry, redactFields(fields))
+}
+
+func redactFields(fields []zapcore.Field) []zapcore.Field {
+	var result []zapcore.Field
+	for i, field := range fields {
+		if !isSensitive(field.Key) {
+			continue
+		}
+		if result == nil {
+			result = append([]zapcore.Field(nil), fields...)
+		}
+		result[i] = zapcore.Field{Key: field.Key, Type: zapcore.StringType, String: redacted}
+	}
+	if result == nil {
+		return fields
+	}
+	return result
+}
+
+func isSensitive(key string) bool {
+	key = strings.ToLower(key)
+	for _, sensitive := range sensitiveKeys {
+		if strings.Contains(key, sensitive) {
+			return true
+		}
+	}
+	return false
+}
+
+func RedactDSN(dsn string) string {
+	if u, err := url.Parse(dsn); err == nil && u.Scheme != "" {
+		query := u.Query()
+		if query.Has("password") {
+			query.Set("password", redacted)
+			u.RawQuery = query.Encode()
+		}
+		return u.Redacted()
+	}
+	return dsnPassword.ReplaceAllString(dsn, "${1}"+redacted)
+}
@@ -0,0 +1,130 @@
+package metrics
+
+import (
+	"database/sql"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+)
+
+const namespace = "gophermart"
+
+var (
+	Registry = prometheus.NewRegistry()
+
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by method, route pattern and status code.",
+	}, []string{"method", "route", "status"})
+
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method, route pattern and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	OpenAPIRouteMisses = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "openapi_route_misses_total",
+		Help:      "Validated requests whose route is missing from the OpenAPI spec.",
+	}, []string{"method", "route"})
+
+	AccrualRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "accrual_requests_total",
+		Help:      "Calls to the accrual service by outcome.",
+	}, []string{"outcome"})
+
+	AccrualRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "accrual_request_duration_seconds",
+		Help:      "Accrual service call latency by outcome.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"outcome"})
+
+	AccrualBreakerState = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "accrual_breaker_state",
+		Help:      "Accrual circuit breaker state: 0 closed, 1 open, 2 half-open.",
+	})
+
+	AccrualBreakerTransitions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "accrual_breaker_transitions_total",
+		Help:      "Accrual circuit breaker transitions by target state.",
+	}, []string{"state"})
+
+	AccrualPendingJobs = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "accrual_pending_jobs",
+		Help:      "Orders waiting for a final accrual status.",
+	})
+
+	AccrualWorkers = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "accrual_workers",
+		Help:      "Configured accrual workers.",
+	})
+
+	AccrualWorkersBusy = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "accrual_workers_busy",
+		Help:      "Accrual workers currently processing an order.",
+	})
+
+	AccrualJobs = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "accrual_jobs_total",
+		Help:      "Processed accrual jobs by result.",
+	}, []string{"result"})
+
+	AccrualInvalidTransitions = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "accrual_invalid_transitions_total",
+		Help:      "Accrual status updates rejected by the order state machine.",
+	})
+
+	Withdrawals = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "withdrawals_total",
+		Help:      "Successful withdrawals.",
+	})
+
+	WithdrawnPoints = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "withdrawn_points_total",
+		Help:      "Points spent by successful withdrawals.",
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequests,
+		HTTPRequestDuration,
+		OpenAPIRouteMisses,
+		AccrualRequests,
+		AccrualRequestDuration,
+		AccrualBreakerState,
+		AccrualBreakerTransitions,
+		AccrualPendingJobs,
+		AccrualWorkers,
+		AccrualWorkersBusy,
+		AccrualJobs,
+		AccrualInvalidTransitions,
+		Withdrawals,
+		WithdrawnPoints,
+	)
+}
+
+func RegisterDB(db *sql.DB) error {
+	return Registry.Register(collectors.NewDBStatsCollector(db, namespace))
+}
+
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
@@ -2,8 +2,13 @@ package middleware
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"github.com/invinciblewest/gophermart/internal/helper"
+	"github.com/invinciblewest/gophermart/internal/logger"
+	"github.com/invinciblewest/gophermart/internal/model"
 	"github.com/invinciblewest/gophermart/internal/usecase"
+	"go.uber.org/zap"
 	"net/http"
 	"strings"
 )
@@ -13,25 +18,30 @@ func AuthMiddleware(authUseCase usecase.AuthUseCase) func(next http.Handler) htt
 		fn := func(w http.ResponseWriter, r *http.Request) {
 			token := r.Header.Get("Authorization")
 			if token == "" {
-				w.WriteHeader(http.StatusUnauthorized)
+				helper.WriteError(w, r, model.ErrUnauthorized)
 				return
 			}
 
 			const prefix = "Bearer "
 			if !strings.HasPrefix(token, prefix) {
-				w.WriteHeader(http.StatusUnauthorized)
+				helper.WriteError(w, r, fmt.Errorf("%w: bearer token expected", model.ErrUnauthorized))
 				return
 			}
 			token = strings.TrimPrefix(token, prefix)
 
-			userID, err := authUseCase.ParseToken(token)
+			claims, err := authUseCase.ParseToken(r.Context(), token)
 			if err != nil {
-				w.WriteHeader(http.StatusUnauthorized)
+				if errors.Is(err, model.ErrInvalidToken) || errors.Is(err, model.ErrTokenRevoked) {
+					err = fmt.Errorf("%w: %w", model.ErrUnauthorized, err)
+				}
+				helper.WriteError(w, r, err)
 				return
 			}
 
 			ctx := r.Context()
-			ctx = context.WithValue(ctx, helper.UserIDKey, userID)
+			ctx = context.WithValue(ctx, helper.UserIDKey, claims.UserID)
+			ctx = context.WithValue(ctx, helper.TokenClaimsKey, claims)
+			ctx = logger.Annotate(ctx, zap.Int("user_id", claims.UserID))
 
 			next.ServeHTTP(w, r.WithContext(ctx))
 		}
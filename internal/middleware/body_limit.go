@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"github.com/invinciblewest/gophermart/internal/helper"
+	"github.com/invinciblewest/gophermart/internal/model"
+	"io"
+	"net/http"
+)
+
+type limitedBody struct {
+	io.ReadCloser
+	tooLarge error
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		err = fmt.Errorf("%w: %w", b.tooLarge, err)
+	}
+	return n, err
+}
+
+func BodyLimitMiddleware(limit int64, tooLarge error) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > limit {
+				helper.WriteError(w, r, fmt.Errorf("%w: body exceeds %d bytes", tooLarge, limit))
+				return
+			}
+
+			r.Body = &limitedBody{ReadCloser: http.MaxBytesReader(w, r.Body, limit), tooLarge: tooLarge}
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+func bodyReadError(err error) error {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", model.ErrMalformedRequest, err)
+}
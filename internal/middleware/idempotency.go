@@ -0,0 +1,175 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/invinciblewest/gophermart/internal/helper"
+	"github.com/invinciblewest/gophermart/internal/logger"
+	"github.com/invinciblewest/gophermart/internal/model"
+	"github.com/invinciblewest/gophermart/internal/usecase"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+)
+
+const (
+	IdempotencyKeyHeader      = "Idempotency-Key"
+	IdempotencyReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength   = 255
+)
+
+var replayedHeaders = []string{"Content-Type", "Location", "ETag", "Retry-After", "X-Content-Type-Options"}
+
+type recordingResponseWriter struct {
+	http.ResponseWriter
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (r *recordingResponseWriter) Header() http.Header {
+	return r.header
+}
+
+func (r *recordingResponseWriter) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.body.Write(b)
+}
+
+func (r *recordingResponseWriter) WriteHeader(statusCode int) {
+	if r.status == 0 {
+		r.status = statusCode
+	}
+}
+
+func (r *recordingResponseWriter) flush(ctx context.Context) {
+	for name, values := range r.header {
+		r.ResponseWriter.Header()[name] = values
+	}
+	r.ResponseWriter.WriteHeader(r.status)
+	if _, err := r.ResponseWriter.Write(r.body.Bytes()); err != nil {
+		logger.FromContext(ctx).Info("failed to write idempotent response", zap.Error(err))
+	}
+}
+
+func IdempotencyMiddleware(idempotencyUseCase usecase.IdempotencyUseCase) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(IdempotencyKeyHeader)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if len(key) > maxIdempotencyKeyLength {
+				helper.WriteError(w, r, fmt.Errorf("%w: longer than %d characters", model.ErrInvalidIdempotencyKey, maxIdempotencyKeyLength))
+				return
+			}
+
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				helper.WriteError(w, r, bodyReadError(err))
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			record := &model.IdempotencyRecord{
+				Key:         key,
+				RequestHash: hashRequest(r, body),
+			}
+			if userID, err := helper.GetUserID(r); err == nil {
+				record.UserID = userID
+			} else {
+				record.Key = anonymousKey(key, record.RequestHash)
+			}
+
+			stored, err := idempotencyUseCase.Reserve(r.Context(), record)
+			if err != nil {
+				helper.WriteError(w, r, err)
+				return
+			}
+
+			if stored != nil {
+				storedHeader := http.Header(stored.ResponseHeader)
+				for _, name := range replayedHeaders {
+					if values := storedHeader.Values(name); len(values) > 0 {
+						w.Header()[http.CanonicalHeaderKey(name)] = values
+					}
+				}
+				w.Header().Set(IdempotencyReplayedHeader, "true")
+				w.WriteHeader(stored.StatusCode)
+				if _, err = w.Write(stored.ResponseBody); err != nil {
+					logger.FromContext(r.Context()).Info("failed to replay idempotent response", zap.Error(err))
+				}
+				return
+			}
+
+			defer func() {
+				if p := recover(); p != nil {
+					if err := idempotencyUseCase.Release(context.WithoutCancel(r.Context()), record); err != nil {
+						logger.FromContext(r.Context()).Info("failed to release idempotency key", zap.Error(err))
+					}
+					panic(p)
+				}
+			}()
+
+			rw := &recordingResponseWriter{ResponseWriter: w, header: make(http.Header)}
+			next.ServeHTTP(rw, r)
+
+			if rw.status == 0 {
+				rw.status = http.StatusOK
+			}
+
+			ctx := context.WithoutCancel(r.Context())
+			if rw.status >= http.StatusInternalServerError {
+				if err = idempotencyUseCase.Release(ctx, record); err != nil {
+					logger.FromContext(ctx).Info("failed to release idempotency key", zap.Error(err))
+				}
+				rw.flush(ctx)
+				return
+			}
+
+			record.StatusCode = rw.status
+			record.ResponseHeader = make(map[string][]string)
+			for _, name := range replayedHeaders {
+				if values := rw.header.Values(name); len(values) > 0 {
+					record.ResponseHeader[http.CanonicalHeaderKey(name)] = values
+				}
+			}
+			record.ResponseBody = rw.body.Bytes()
+			if err = idempotencyUseCase.Complete(ctx, record); err != nil {
+				if blockErr := idempotencyUseCase.Block(ctx, record); blockErr != nil {
+					logger.FromContext(ctx).Error("failed to block idempotency key", zap.Error(blockErr))
+				}
+				helper.WriteError(w, r, fmt.Errorf("failed to save idempotent response: %w", err))
+				return
+			}
+			rw.flush(ctx)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+func anonymousKey(key string, requestHash string) string {
+	hash := sha256.New()
+	hash.Write([]byte(key))
+	hash.Write([]byte{0})
+	hash.Write([]byte(requestHash))
+	return "anonymous:" + hex.EncodeToString(hash.Sum(nil))
+}
+
+func hashRequest(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(r.Method))
+	hash.Write([]byte{0})
+	hash.Write([]byte(r.URL.Path))
+	hash.Write([]byte{0})
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
@@ -0,0 +1,140 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"github.com/invinciblewest/gophermart/internal/model"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type memoryIdempotencyUseCase struct {
+	mu          sync.Mutex
+	records     map[string]*model.IdempotencyRecord
+	completeErr error
+	blocked     []string
+}
+
+func newMemoryIdempotencyUseCase() *memoryIdempotencyUseCase {
+	return &memoryIdempotencyUseCase{records: make(map[string]*model.IdempotencyRecord)}
+}
+
+func (s *memoryIdempotencyUseCase) Reserve(_ context.Context, record *model.IdempotencyRecord) (*model.IdempotencyRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored, ok := s.records[record.Key]
+	if !ok {
+		s.records[record.Key] = &model.IdempotencyRecord{Key: record.Key, RequestHash: record.RequestHash}
+		return nil, nil
+	}
+	if !stored.Completed() {
+		return nil, model.ErrIdempotencyKeyInProgress
+	}
+	return stored, nil
+}
+
+func (s *memoryIdempotencyUseCase) Complete(_ context.Context, record *model.IdempotencyRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.completeErr != nil {
+		return s.completeErr
+	}
+	stored := *record
+	s.records[record.Key] = &stored
+	return nil
+}
+
+func (s *memoryIdempotencyUseCase) Release(_ context.Context, record *model.IdempotencyRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, record.Key)
+	return nil
+}
+
+func (s *memoryIdempotencyUseCase) Block(_ context.Context, record *model.IdempotencyRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.blocked = append(s.blocked, record.Key)
+	return nil
+}
+
+func (s *memoryIdempotencyUseCase) Purge(context.Context) (int64, error) {
+	return 0, nil
+}
+
+func serveIdempotent(handler http.Handler, key string, body string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodPost, "/api/user/balance/withdraw", strings.NewReader(body))
+	request.Header.Set(IdempotencyKeyHeader, key)
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestIdempotencyMiddlewareReplaysResponse(t *testing.T) {
+	store := newMemoryIdempotencyUseCase()
+	calls := 0
+	handler := IdempotencyMiddleware(store)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Location", "/api/user/withdrawals/1")
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	first := serveIdempotent(handler, "key", `{"sum":1}`)
+	second := serveIdempotent(handler, "key", `{"sum":1}`)
+
+	if calls != 1 {
+		t.Errorf("handler ran %d times, want 1", calls)
+	}
+	if first.Code != http.StatusCreated || second.Code != http.StatusCreated {
+		t.Errorf("statuses = %d, %d, want %d", first.Code, second.Code, http.StatusCreated)
+	}
+	if second.Header().Get(IdempotencyReplayedHeader) != "true" || second.Header().Get("Location") != "/api/user/withdrawals/1" {
+		t.Errorf("replayed headers = %v", second.Header())
+	}
+}
+
+func TestIdempotencyMiddlewareBlocksKeyWhenSaveFails(t *testing.T) {
+	store := newMemoryIdempotencyUseCase()
+	store.completeErr = errors.New("connection reset")
+	calls := 0
+	handler := IdempotencyMiddleware(store)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Authorization", "Bearer secret")
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	first := serveIdempotent(handler, "key", `{"sum":1}`)
+	if first.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want %d", first.Code, http.StatusInternalServerError)
+	}
+	if first.Header().Get("Authorization") != "" {
+		t.Error("failed response leaked the handler headers")
+	}
+	if len(store.blocked) != 1 {
+		t.Errorf("blocked keys = %v, want the failed key", store.blocked)
+	}
+
+	second := serveIdempotent(handler, "key", `{"sum":1}`)
+	if second.Code != http.StatusConflict || calls != 1 {
+		t.Errorf("retry = %d after %d handler calls, want %d after 1", second.Code, calls, http.StatusConflict)
+	}
+}
+
+func TestIdempotencyMiddlewareScopesAnonymousKeysByRequest(t *testing.T) {
+	store := newMemoryIdempotencyUseCase()
+	calls := 0
+	handler := IdempotencyMiddleware(store)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	}))
+
+	serveIdempotent(handler, "key", `{"login":"first"}`)
+	serveIdempotent(handler, "key", `{"login":"second"}`)
+	serveIdempotent(handler, "key", `{"login":"first"}`)
+
+	if calls != 2 {
+		t.Errorf("handler ran %d times, want 2", calls)
+	}
+}
@@ -1,7 +1,10 @@
 package middleware
 
 import (
+	"github.com/go-chi/chi/v5"
+	chiMiddleware "github.com/go-chi/chi/v5/middleware"
 	"github.com/invinciblewest/gophermart/internal/logger"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	"net/http"
 	"time"
@@ -34,6 +37,13 @@ func LoggerMiddleware(next http.Handler) http.Handler {
 	fn := func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 
+		fields := []zap.Field{zap.String("request_id", chiMiddleware.GetReqID(r.Context()))}
+		if spanContext := trace.SpanContextFromContext(r.Context()); spanContext.IsValid() {
+			fields = append(fields, zap.String("trace_id", spanContext.TraceID().String()))
+		}
+		log := logger.FromContext(r.Context()).With(fields...)
+		r = r.WithContext(logger.WithRequestScope(r.Context(), log))
+
 		rd := &responseData{
 			status: 200,
 			size:   0,
@@ -45,8 +55,9 @@ func LoggerMiddleware(next http.Handler) http.Handler {
 
 		next.ServeHTTP(&lw, r)
 
-		logger.Log.Info("got incoming HTTP request",
-			zap.String("uri", r.RequestURI),
+		logger.RequestLogger(r.Context()).Info("got incoming HTTP request",
+			zap.String("route", routePattern(r)),
+			zap.String("uri", r.URL.Path),
 			zap.String("method", r.Method),
 			zap.Int("status", rd.status),
 			zap.Int("size", rd.size),
@@ -56,3 +67,19 @@ func LoggerMiddleware(next http.Handler) http.Handler {
 
 	return http.HandlerFunc(fn)
 }
+
+func RouteLoggerMiddleware(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		ctx := logger.With(r.Context(), zap.String("route", routePattern(r)))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	}
+
+	return http.HandlerFunc(fn)
+}
+
+func routePattern(r *http.Request) string {
+	if rctx := chi.RouteContext(r.Context()); rctx != nil {
+		return rctx.RoutePattern()
+	}
+	return ""
+}
@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"github.com/go-chi/chi/v5"
+	"github.com/invinciblewest/gophermart/internal/metrics"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+func MetricsMiddleware(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		rd := &responseData{
+			status: 200,
+			size:   0,
+		}
+		lw := loggingResponseWriter{
+			ResponseWriter: w,
+			responseData:   rd,
+		}
+
+		next.ServeHTTP(&lw, r)
+
+		route := "unmatched"
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+		status := strconv.Itoa(rd.status)
+
+		metrics.HTTPRequests.WithLabelValues(r.Method, route, status).Inc()
+		metrics.HTTPRequestDuration.WithLabelValues(r.Method, route, status).Observe(time.Since(start).Seconds())
+	}
+
+	return http.HandlerFunc(fn)
+}
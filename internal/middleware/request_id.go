@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	chiMiddleware "github.com/go-chi/chi/v5/middleware"
+	"net/http"
+	"strconv"
+)
+
+const (
+	RequestIDHeader    = "X-Request-ID"
+	maxRequestIDLength = 128
+)
+
+func RequestIDMiddleware(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = newRequestID()
+		}
+
+		w.Header().Set(RequestIDHeader, requestID)
+		ctx := context.WithValue(r.Context(), chiMiddleware.RequestIDKey, requestID)
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	}
+
+	return http.HandlerFunc(fn)
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		if c < 0x21 || c > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatUint(chiMiddleware.NextRequestID(), 10)
+	}
+	return hex.EncodeToString(b)
+}
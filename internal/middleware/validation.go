@@ -0,0 +1,100 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers/legacy"
+	"github.com/invinciblewest/gophermart/internal/helper"
+	"github.com/invinciblewest/gophermart/internal/logger"
+	"github.com/invinciblewest/gophermart/internal/metrics"
+	"github.com/invinciblewest/gophermart/internal/model"
+	"go.uber.org/zap"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const invalidContentTypeReason = "header Content-Type has unexpected value"
+
+func ValidationMiddleware(doc *openapi3.T) (func(next http.Handler) http.Handler, error) {
+	router, err := legacy.NewRouter(doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build openapi router: %w", err)
+	}
+
+	options := &openapi3filter.Options{
+		AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+	}
+
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			route, pathParams, err := router.FindRoute(routeRequest(r))
+			if err != nil {
+				metrics.OpenAPIRouteMisses.WithLabelValues(r.Method, routePattern(r)).Inc()
+				logger.FromContext(r.Context()).Error("route is missing from the openapi spec",
+					zap.String("path", r.URL.Path), zap.Error(err))
+				helper.WriteError(w, r, fmt.Errorf("route %s %s is missing from the openapi spec: %w", r.Method, r.URL.Path, err))
+				return
+			}
+
+			input := &openapi3filter.RequestValidationInput{
+				Request:    r,
+				PathParams: pathParams,
+				Route:      route,
+				Options:    options,
+			}
+			if err = openapi3filter.ValidateRequest(r.Context(), input); err != nil {
+				helper.WriteError(w, r, validationError(err))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}, nil
+}
+
+func routeRequest(r *http.Request) *http.Request {
+	if r.URL.Host == "" && r.URL.Scheme == "" {
+		return r
+	}
+	routed := r.Clone(r.Context())
+	routed.URL = &url.URL{Path: r.URL.Path, RawPath: r.URL.RawPath, RawQuery: r.URL.RawQuery}
+	return routed
+}
+
+func validationError(err error) error {
+	var requestErr *openapi3filter.RequestError
+	if !errors.As(err, &requestErr) {
+		return bodyReadError(err)
+	}
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return err
+	}
+	if strings.HasPrefix(requestErr.Reason, invalidContentTypeReason) {
+		return fmt.Errorf("%w: %s", model.ErrInvalidContentType, requestErr.Reason)
+	}
+
+	location := "request body"
+	if requestErr.Parameter != nil {
+		location = fmt.Sprintf("parameter %q in %s", requestErr.Parameter.Name, requestErr.Parameter.In)
+	}
+
+	var schemaErr *openapi3.SchemaError
+	if errors.As(err, &schemaErr) {
+		if pointer := schemaErr.JSONPointer(); len(pointer) > 0 {
+			location += " at /" + strings.Join(pointer, "/")
+		}
+		return fmt.Errorf("%w: %s: %s", model.ErrMalformedRequest, location, schemaErr.Reason)
+	}
+
+	reason := requestErr.Reason
+	if requestErr.Err != nil {
+		reason = requestErr.Err.Error()
+	}
+	return fmt.Errorf("%w: %s: %s", model.ErrMalformedRequest, location, reason)
+}
@@ -1,7 +1,29 @@
 package model
 
+type AccrualStatus string
+
+const (
+	AccrualStatusRegistered AccrualStatus = "REGISTERED"
+	AccrualStatusProcessing AccrualStatus = "PROCESSING"
+	AccrualStatusInvalid    AccrualStatus = "INVALID"
+	AccrualStatusProcessed  AccrualStatus = "PROCESSED"
+)
+
+var accrualToOrderStatus = map[AccrualStatus]OrderStatus{
+	AccrualStatusRegistered: OrderStatusProcessing,
+	AccrualStatusProcessing: OrderStatusProcessing,
+	AccrualStatusInvalid:    OrderStatusInvalid,
+	AccrualStatusProcessed:  OrderStatusProcessed,
+}
+
+func (s AccrualStatus) OrderStatus() (OrderStatus, bool) {
+	status, ok := accrualToOrderStatus[s]
+	return status, ok
+}
+
 type AccrualResponse struct {
-	Order   string      `json:"order"`
-	Status  OrderStatus `json:"status"`
-	Accrual Amount      `json:"accrual,omitempty"`
+	Order   string        `json:"order"`
+	Status  AccrualStatus `json:"status"`
+	Accrual Amount        `json:"accrual,omitempty"`
+	Raw     []byte        `json:"-"`
 }
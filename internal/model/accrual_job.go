@@ -0,0 +1,14 @@
+package model
+
+import "time"
+
+type AccrualJob struct {
+	OrderNumber   string
+	Attempts      int
+	NextAttemptAt time.Time
+	LockedBy      string
+	LockedUntil   time.Time
+	LeaseToken    string
+	LastError     string
+	CreatedAt     time.Time
+}
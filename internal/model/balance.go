@@ -4,3 +4,9 @@ type Balance struct {
 	Current   Amount `json:"current"`
 	Withdrawn Amount `json:"withdrawn"`
 }
+
+type BalanceDrift struct {
+	UserID   int
+	Stored   Balance
+	Expected Balance
+}
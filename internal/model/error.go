@@ -3,14 +3,33 @@ package model
 import "errors"
 
 var (
+	ErrUnauthorized                     = errors.New("unauthorized")
+	ErrMalformedRequest                 = errors.New("malformed request")
+	ErrInvalidContentType               = errors.New("invalid content type")
 	ErrOrderNotFound                    = errors.New("order not found")
 	ErrInvalidOrderNumber               = errors.New("invalid order number")
 	ErrOrderAlreadyExists               = errors.New("order already exists")
 	ErrOrderAlreadyExistsForAnotherUser = errors.New("order already exists for another user")
 	ErrInvalidWithdrawSum               = errors.New("invalid withdraw sum")
+	ErrInvalidWithdrawAmount            = errors.New("withdraw sum must be positive")
 	ErrWithdrawalNotFound               = errors.New("withdrawal not found")
 	ErrEmptyLoginOrPassword             = errors.New("login or password is empty")
 	ErrUserAlreadyExists                = errors.New("user already exists")
 	ErrUserNotFound                     = errors.New("user not found")
 	ErrInvalidPassword                  = errors.New("invalid password")
+	ErrUserDisabled                     = errors.New("user is disabled")
+	ErrEmptyOrderBatch                  = errors.New("order batch is empty")
+	ErrOrderBatchTooLarge               = errors.New("order batch is too large")
+	ErrInvalidListQuery                 = errors.New("invalid list query")
+	ErrInvalidStatusTransition          = errors.New("invalid order status transition")
+	ErrUnknownAccrualStatus             = errors.New("unknown accrual status")
+	ErrAccrualLeaseLost                 = errors.New("accrual job lease lost")
+	ErrInvalidToken                     = errors.New("invalid token")
+	ErrTokenRevoked                     = errors.New("token revoked")
+	ErrRefreshTokenReused               = errors.New("refresh token reused")
+	ErrInvalidIdempotencyKey            = errors.New("invalid idempotency key")
+	ErrIdempotencyKeyNotFound           = errors.New("idempotency key not found")
+	ErrIdempotencyKeyExists             = errors.New("idempotency key already exists")
+	ErrIdempotencyKeyReused             = errors.New("idempotency key reused with a different request")
+	ErrIdempotencyKeyInProgress         = errors.New("request with this idempotency key is in progress")
 )
@@ -0,0 +1,12 @@
+package model
+
+import "time"
+
+type UserExport struct {
+	Login       string       `json:"login"`
+	CreatedAt   time.Time    `json:"created_at"`
+	DisabledAt  *time.Time   `json:"disabled_at,omitempty"`
+	Balance     Balance      `json:"balance"`
+	Orders      []Order      `json:"orders"`
+	Withdrawals []Withdrawal `json:"withdrawals"`
+}
@@ -0,0 +1,21 @@
+package model
+
+type HealthStatus string
+
+const (
+	HealthStatusOK   HealthStatus = "ok"
+	HealthStatusFail HealthStatus = "fail"
+)
+
+type HealthCheck struct {
+	Name       string         `json:"name"`
+	Status     HealthStatus   `json:"status"`
+	Error      string         `json:"error,omitempty"`
+	DurationMs float64        `json:"duration_ms"`
+	Details    map[string]any `json:"details,omitempty"`
+}
+
+type HealthReport struct {
+	Status HealthStatus  `json:"status"`
+	Checks []HealthCheck `json:"checks,omitempty"`
+}
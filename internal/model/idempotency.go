@@ -0,0 +1,17 @@
+package model
+
+import "time"
+
+type IdempotencyRecord struct {
+	UserID         int
+	Key            string
+	RequestHash    string
+	StatusCode     int
+	ResponseHeader map[string][]string
+	ResponseBody   []byte
+	CreatedAt      time.Time
+}
+
+func (r *IdempotencyRecord) Completed() bool {
+	return r.StatusCode != 0
+}
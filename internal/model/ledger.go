@@ -0,0 +1,36 @@
+package model
+
+import "time"
+
+type LedgerKind string
+
+const (
+	LedgerKindAccrual    LedgerKind = "accrual"
+	LedgerKindWithdrawal LedgerKind = "withdrawal"
+)
+
+type LedgerAccount string
+
+const (
+	LedgerAccountUser        LedgerAccount = "user"
+	LedgerAccountAccruals    LedgerAccount = "accruals"
+	LedgerAccountWithdrawals LedgerAccount = "withdrawals"
+)
+
+type LedgerDirection string
+
+const (
+	LedgerDirectionDebit  LedgerDirection = "debit"
+	LedgerDirectionCredit LedgerDirection = "credit"
+)
+
+type LedgerEntry struct {
+	ID          int64
+	UserID      int
+	Kind        LedgerKind
+	Account     LedgerAccount
+	Direction   LedgerDirection
+	Amount      Amount
+	OrderNumber string
+	CreatedAt   time.Time
+}
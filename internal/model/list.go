@@ -0,0 +1,51 @@
+package model
+
+import (
+	"encoding/base64"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Cursor struct {
+	Time time.Time
+	ID   int
+}
+
+func (c Cursor) Encode() string {
+	raw := c.Time.UTC().Format(time.RFC3339Nano) + "|" + strconv.Itoa(c.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func DecodeCursor(value string) (*Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, ErrInvalidListQuery
+	}
+
+	timePart, idPart, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return nil, ErrInvalidListQuery
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, timePart)
+	if err != nil {
+		return nil, ErrInvalidListQuery
+	}
+
+	id, err := strconv.Atoi(idPart)
+	if err != nil {
+		return nil, ErrInvalidListQuery
+	}
+
+	return &Cursor{Time: t, ID: id}, nil
+}
+
+type ListQuery struct {
+	Limit     int
+	Cursor    *Cursor
+	Ascending bool
+	Statuses  []OrderStatus
+	From      *time.Time
+	To        *time.Time
+}
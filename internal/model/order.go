@@ -21,3 +21,35 @@ type Order struct {
 	Accrual    *Amount     `json:"accrual,omitempty"`
 	UploadedAt time.Time   `json:"uploaded_at"`
 }
+
+type OrderStatusUpdate struct {
+	Number      string
+	Status      OrderStatus
+	Accrual     *Amount
+	Reason      string
+	Source      OrderEventSource
+	RawResponse []byte
+	LeaseToken  string
+}
+
+var orderTransitions = map[OrderStatus][]OrderStatus{
+	OrderStatusNew:        {OrderStatusProcessing, OrderStatusInvalid, OrderStatusProcessed},
+	OrderStatusProcessing: {OrderStatusInvalid, OrderStatusProcessed},
+}
+
+func (s OrderStatus) IsFinal() bool {
+	return s == OrderStatusInvalid || s == OrderStatusProcessed
+}
+
+func (s OrderStatus) CanTransitionTo(next OrderStatus) bool {
+	if s == next {
+		return !s.IsFinal()
+	}
+
+	for _, allowed := range orderTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
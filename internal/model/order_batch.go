@@ -0,0 +1,16 @@
+package model
+
+type OrderBatchStatus string
+
+const (
+	OrderBatchAccepted           OrderBatchStatus = "accepted"
+	OrderBatchAlreadyYours       OrderBatchStatus = "already_yours"
+	OrderBatchOwnedByAnotherUser OrderBatchStatus = "owned_by_another_user"
+	OrderBatchInvalid            OrderBatchStatus = "invalid"
+)
+
+type OrderBatchResult struct {
+	Number string           `json:"number"`
+	Status OrderBatchStatus `json:"status"`
+	Reason string           `json:"reason,omitempty"`
+}
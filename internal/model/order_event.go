@@ -0,0 +1,28 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type OrderEventSource string
+
+const (
+	OrderEventSourceUser     OrderEventSource = "user"
+	OrderEventSourcePoller   OrderEventSource = "poller"
+	OrderEventSourceAdmin    OrderEventSource = "admin"
+	OrderEventSourceWebhook  OrderEventSource = "webhook"
+	OrderEventSourceBackfill OrderEventSource = "backfill"
+)
+
+type OrderEvent struct {
+	ID          int64            `json:"-"`
+	OrderNumber string           `json:"-"`
+	FromStatus  *OrderStatus     `json:"from_status,omitempty"`
+	ToStatus    OrderStatus      `json:"to_status"`
+	Accrual     *Amount          `json:"accrual,omitempty"`
+	Reason      string           `json:"reason,omitempty"`
+	Source      OrderEventSource `json:"source"`
+	RawResponse json.RawMessage  `json:"accrual_response,omitempty"`
+	CreatedAt   time.Time        `json:"created_at"`
+}
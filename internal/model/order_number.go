@@ -0,0 +1,13 @@
+package model
+
+type OrderNumberError struct {
+	Reason string
+}
+
+func (e *OrderNumberError) Error() string {
+	return ErrInvalidOrderNumber.Error() + ": " + e.Reason
+}
+
+func (e *OrderNumberError) Is(target error) bool {
+	return target == ErrInvalidOrderNumber
+}
@@ -0,0 +1,32 @@
+package model
+
+import "time"
+
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+type TokenClaims struct {
+	ID        string
+	UserID    int
+	FamilyID  string
+	ExpiresAt time.Time
+}
+
+type RefreshToken struct {
+	ID        int
+	UserID    int
+	FamilyID  string
+	TokenHash string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	RevokedAt *time.Time
+	CreatedAt time.Time
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
@@ -0,0 +1,23 @@
+package openapi
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+//go:embed openapi.yaml
+var spec []byte
+
+func Load() (*openapi3.T, error) {
+	loader := openapi3.NewLoader()
+	doc, err := loader.LoadFromData(spec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse openapi spec: %w", err)
+	}
+	if err = doc.Validate(context.Background()); err != nil {
+		return nil, fmt.Errorf("invalid openapi spec: %w", err)
+	}
+	return doc, nil
+}
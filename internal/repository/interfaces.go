@@ -3,26 +3,67 @@ package repository
 import (
 	"context"
 	"github.com/invinciblewest/gophermart/internal/model"
+	"time"
 )
 
 type UserRepository interface {
 	CreateUser(ctx context.Context, user *model.User) error
 	GetUserByLogin(ctx context.Context, login string) (*model.User, error)
+	UpdatePassword(ctx context.Context, userID int, password string) error
+	DisableUser(ctx context.Context, userID int) error
+	ListUsers(ctx context.Context, afterID int, limit int) ([]model.User, error)
+}
+
+type TokenRepository interface {
+	CreateRefreshToken(ctx context.Context, token *model.RefreshToken) error
+	GetRefreshToken(ctx context.Context, tokenHash string) (*model.RefreshToken, error)
+	MarkRefreshTokenUsed(ctx context.Context, id int) (bool, error)
+	RevokeTokenFamily(ctx context.Context, familyID string) error
+	RevokeUserTokens(ctx context.Context, userID int) error
+	RevokeAccessToken(ctx context.Context, jti string, expiresAt time.Time) error
+	IsAccessTokenRevoked(ctx context.Context, jti string, userID int) (bool, error)
+	PurgeExpiredTokens(ctx context.Context) (int64, error)
 }
 
 type OrderRepository interface {
 	AddOrder(ctx context.Context, order *model.Order) error
-	GetOrderByUser(ctx context.Context, userID int) ([]model.Order, error)
+	AddOrders(ctx context.Context, userID int, numbers []string) (map[string]model.OrderBatchStatus, error)
+	GetOrderByUser(ctx context.Context, userID int, query model.ListQuery) ([]model.Order, error)
 	GetOrderByNumber(ctx context.Context, number string) (*model.Order, error)
-	UpdateOrderStatus(ctx context.Context, number string, status model.OrderStatus, accrual *model.Amount) error
-	GetPendingOrders(ctx context.Context) ([]model.Order, error)
+	UpdateOrderStatus(ctx context.Context, update model.OrderStatusUpdate) error
+	GetOrderEvents(ctx context.Context, number string) ([]model.OrderEvent, error)
+	RequeueOrder(ctx context.Context, number string) error
+}
+
+type AccrualJobRepository interface {
+	ClaimAccrualJobs(ctx context.Context, workerID string, limit int, lease time.Duration) ([]model.AccrualJob, error)
+	RescheduleAccrualJob(ctx context.Context, leaseToken string, number string, nextAttemptAt time.Time, lastError string) error
+	ReleaseAccrualJob(ctx context.Context, leaseToken string, number string, nextAttemptAt time.Time) error
+	CompleteAccrualJob(ctx context.Context, leaseToken string, number string) error
+	CountAccrualJobs(ctx context.Context) (int, error)
 }
 
 type WithdrawalRepository interface {
-	CreateWithdrawal(ctx context.Context, withdrawal *model.Withdrawal) error
-	GetWithdrawalByUser(ctx context.Context, userID int) ([]model.Withdrawal, error)
+	GetWithdrawalByUser(ctx context.Context, userID int, query model.ListQuery) ([]model.Withdrawal, error)
 }
 
-type BalanceRepository interface {
+type LedgerRepository interface {
 	GetBalanceByUser(ctx context.Context, userID int) (*model.Balance, error)
+	CreateWithdrawal(ctx context.Context, withdrawal *model.Withdrawal) error
+	ReconcileBalances(ctx context.Context, fix bool) ([]model.BalanceDrift, error)
+	RecomputeBalance(ctx context.Context, userID int) (*model.BalanceDrift, error)
+}
+
+type IdempotencyRepository interface {
+	CreateIdempotencyKey(ctx context.Context, record *model.IdempotencyRecord, lease time.Duration) error
+	GetIdempotencyKey(ctx context.Context, userID int, key string) (*model.IdempotencyRecord, error)
+	SaveIdempotencyResponse(ctx context.Context, record *model.IdempotencyRecord) error
+	DeleteIdempotencyKey(ctx context.Context, userID int, key string) error
+	LockIdempotencyKey(ctx context.Context, userID int, key string, until time.Time) error
+	PurgeIdempotencyKeys(ctx context.Context, createdBefore time.Time) (int64, error)
+}
+
+type HealthRepository interface {
+	Ping(ctx context.Context) error
+	GetMigrationVersion(ctx context.Context) (int64, error)
 }
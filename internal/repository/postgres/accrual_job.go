@@ -0,0 +1,140 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/invinciblewest/gophermart/internal/logger"
+	"github.com/invinciblewest/gophermart/internal/model"
+	"go.uber.org/zap"
+	"time"
+)
+
+func (r *PGRepository) ClaimAccrualJobs(ctx context.Context, workerID string, limit int, lease time.Duration) ([]model.AccrualJob, error) {
+	query := `UPDATE accrual_jobs
+	SET locked_by = $1, locked_until = now() + make_interval(secs => $2),
+	    lease_token = md5(random()::text || clock_timestamp()::text || order_number), updated_at = now()
+	WHERE order_number IN (
+	  SELECT order_number FROM accrual_jobs
+	  WHERE next_attempt_at <= now() AND (locked_until IS NULL OR locked_until < now())
+	  ORDER BY next_attempt_at
+	  LIMIT $3
+	  FOR UPDATE SKIP LOCKED
+	)
+	RETURNING order_number, attempts, next_attempt_at, locked_by, locked_until, lease_token, COALESCE(last_error, ''), created_at`
+
+	rows, err := r.db.QueryContext(ctx, query, workerID, lease.Seconds(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		if err = rows.Close(); err != nil {
+			logger.FromContext(ctx).Info("failed to close rows", zap.Error(err))
+		}
+	}(rows)
+
+	var jobs []model.AccrualJob
+	for rows.Next() {
+		var job model.AccrualJob
+		if err = rows.Scan(&job.OrderNumber, &job.Attempts, &job.NextAttemptAt,
+			&job.LockedBy, &job.LockedUntil, &job.LeaseToken, &job.LastError, &job.CreatedAt); err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return jobs, nil
+}
+
+func (r *PGRepository) RescheduleAccrualJob(
+	ctx context.Context,
+	leaseToken string,
+	number string,
+	nextAttemptAt time.Time,
+	lastError string,
+) error {
+	result, err := r.db.ExecContext(ctx,
+		`UPDATE accrual_jobs
+		SET attempts = attempts + 1, next_attempt_at = $1, last_error = NULLIF($2, ''),
+		    locked_by = NULL, locked_until = NULL, lease_token = NULL, updated_at = now()
+		WHERE order_number = $3 AND lease_token = $4 AND locked_until > now()`,
+		nextAttemptAt, lastError, number, leaseToken)
+	return checkLease(result, err)
+}
+
+func (r *PGRepository) ReleaseAccrualJob(ctx context.Context, leaseToken string, number string, nextAttemptAt time.Time) error {
+	result, err := r.db.ExecContext(ctx,
+		`UPDATE accrual_jobs
+		SET next_attempt_at = $1, locked_by = NULL, locked_until = NULL, lease_token = NULL, updated_at = now()
+		WHERE order_number = $2 AND lease_token = $3 AND locked_until > now()`,
+		nextAttemptAt, number, leaseToken)
+	return checkLease(result, err)
+}
+
+func (r *PGRepository) CompleteAccrualJob(ctx context.Context, leaseToken string, number string) error {
+	result, err := r.db.ExecContext(ctx,
+		"DELETE FROM accrual_jobs WHERE order_number = $1 AND lease_token = $2 AND locked_until > now()",
+		number, leaseToken)
+	return checkLease(result, err)
+}
+
+func (r *PGRepository) CountAccrualJobs(ctx context.Context) (int, error) {
+	var count int
+	if err := r.db.QueryRowContext(ctx, "SELECT count(*) FROM accrual_jobs").Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func enqueueAccrualJob(ctx context.Context, q querier, number string) error {
+	_, err := q.ExecContext(ctx,
+		"INSERT INTO accrual_jobs (order_number) VALUES ($1) ON CONFLICT (order_number) DO NOTHING",
+		number)
+	return err
+}
+
+func completeAccrualJob(ctx context.Context, q querier, number string) error {
+	_, err := q.ExecContext(ctx, "DELETE FROM accrual_jobs WHERE order_number = $1", number)
+	return err
+}
+
+func lockAccrualJob(ctx context.Context, q querier, leaseToken string, number string) error {
+	var held bool
+	err := q.QueryRowContext(ctx,
+		`SELECT lease_token = $2 AND locked_until > now() FROM accrual_jobs
+		WHERE order_number = $1 FOR UPDATE`,
+		number, leaseToken).Scan(&held)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.ErrAccrualLeaseLost
+	}
+	if err != nil {
+		return err
+	}
+	if !held {
+		return model.ErrAccrualLeaseLost
+	}
+	return nil
+}
+
+func lockAccrualJobRow(ctx context.Context, q querier, number string) error {
+	_, err := q.ExecContext(ctx, "SELECT 1 FROM accrual_jobs WHERE order_number = $1 FOR UPDATE", number)
+	return err
+}
+
+func checkLease(result sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return model.ErrAccrualLeaseLost
+	}
+	return nil
+}
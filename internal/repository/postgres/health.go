@@ -0,0 +1,14 @@
+package postgres
+
+import "context"
+
+func (r *PGRepository) Ping(ctx context.Context) error {
+	return r.db.PingContext(ctx)
+}
+
+func (r *PGRepository) GetMigrationVersion(ctx context.Context) (int64, error) {
+	var version int64
+	err := r.db.QueryRowContext(ctx,
+		"SELECT COALESCE(max(version_id), 0) FROM goose_db_version WHERE is_applied").Scan(&version)
+	return version, err
+}
@@ -0,0 +1,98 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/invinciblewest/gophermart/internal/model"
+	"time"
+)
+
+func (r *PGRepository) CreateIdempotencyKey(ctx context.Context, record *model.IdempotencyRecord, lease time.Duration) error {
+	query := `INSERT INTO idempotency_keys (user_id, key, request_hash, locked_until)
+	VALUES ($1, $2, $3, now() + make_interval(secs => $4))
+	ON CONFLICT (user_id, key) DO UPDATE
+	SET locked_until = EXCLUDED.locked_until, created_at = now()
+	WHERE idempotency_keys.status_code IS NULL
+	  AND idempotency_keys.request_hash = EXCLUDED.request_hash
+	  AND (idempotency_keys.locked_until IS NULL OR idempotency_keys.locked_until < now())
+	RETURNING created_at`
+
+	err := r.db.QueryRowContext(ctx, query,
+		record.UserID, record.Key, record.RequestHash, lease.Seconds()).Scan(&record.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.ErrIdempotencyKeyExists
+		}
+		return err
+	}
+	return nil
+}
+
+func (r *PGRepository) GetIdempotencyKey(ctx context.Context, userID int, key string) (*model.IdempotencyRecord, error) {
+	record := model.IdempotencyRecord{
+		UserID: userID,
+		Key:    key,
+	}
+
+	var statusCode sql.NullInt64
+	var header []byte
+	err := r.db.QueryRowContext(ctx,
+		`SELECT request_hash, status_code, response_header, response_body, created_at
+		FROM idempotency_keys WHERE user_id = $1 AND key = $2`,
+		userID, key).Scan(&record.RequestHash, &statusCode, &header, &record.ResponseBody, &record.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, model.ErrIdempotencyKeyNotFound
+		}
+		return nil, err
+	}
+
+	record.StatusCode = int(statusCode.Int64)
+	if len(header) > 0 {
+		if err = json.Unmarshal(header, &record.ResponseHeader); err != nil {
+			return nil, err
+		}
+	}
+
+	return &record, nil
+}
+
+func (r *PGRepository) SaveIdempotencyResponse(ctx context.Context, record *model.IdempotencyRecord) error {
+	header, err := json.Marshal(record.ResponseHeader)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx,
+		`UPDATE idempotency_keys SET status_code = $1, response_header = $2, response_body = $3
+		WHERE user_id = $4 AND key = $5`,
+		record.StatusCode, string(header), record.ResponseBody, record.UserID, record.Key)
+	return err
+}
+
+func (r *PGRepository) DeleteIdempotencyKey(ctx context.Context, userID int, key string) error {
+	_, err := r.db.ExecContext(ctx,
+		"DELETE FROM idempotency_keys WHERE user_id = $1 AND key = $2",
+		userID, key)
+	return err
+}
+
+func (r *PGRepository) LockIdempotencyKey(ctx context.Context, userID int, key string, until time.Time) error {
+	_, err := r.db.ExecContext(ctx,
+		"UPDATE idempotency_keys SET locked_until = $1 WHERE user_id = $2 AND key = $3 AND status_code IS NULL",
+		until, userID, key)
+	return err
+}
+
+func (r *PGRepository) PurgeIdempotencyKeys(ctx context.Context, createdBefore time.Time) (int64, error) {
+	result, err := r.db.ExecContext(ctx,
+		`DELETE FROM idempotency_keys
+		WHERE created_at < $1 AND (status_code IS NOT NULL OR locked_until IS NULL OR locked_until < now())`,
+		createdBefore)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
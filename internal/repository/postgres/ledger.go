@@ -0,0 +1,217 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/invinciblewest/gophermart/internal/logger"
+	"github.com/invinciblewest/gophermart/internal/model"
+	"go.uber.org/zap"
+)
+
+func (r *PGRepository) GetBalanceByUser(ctx context.Context, userID int) (*model.Balance, error) {
+	var balance model.Balance
+
+	err := r.db.QueryRowContext(ctx,
+		"SELECT current, withdrawn FROM balances WHERE user_id = $1",
+		userID).Scan(&balance.Current, &balance.Withdrawn)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &balance, nil
+		}
+		return nil, err
+	}
+
+	return &balance, nil
+}
+
+func (r *PGRepository) CreateWithdrawal(ctx context.Context, withdrawal *model.Withdrawal) error {
+	return r.withTx(ctx, func(tx *sql.Tx) error {
+		balance, err := lockBalance(ctx, tx, withdrawal.UserID)
+		if err != nil {
+			return err
+		}
+
+		if balance.Current < withdrawal.Amount {
+			return model.ErrInvalidWithdrawSum
+		}
+
+		query := `INSERT INTO withdrawals (user_id, order_number, amount)
+			VALUES ($1, $2, $3) RETURNING id, processed_at`
+		err = tx.QueryRowContext(ctx, query,
+			withdrawal.UserID, withdrawal.OrderNumber, withdrawal.Amount,
+		).Scan(&withdrawal.ID, &withdrawal.ProcessedAt)
+		if err != nil {
+			return err
+		}
+
+		return postTransfer(ctx, tx, model.LedgerEntry{
+			UserID:      withdrawal.UserID,
+			Kind:        model.LedgerKindWithdrawal,
+			Amount:      withdrawal.Amount,
+			OrderNumber: withdrawal.OrderNumber,
+		}, model.LedgerAccountUser, model.LedgerAccountWithdrawals)
+	})
+}
+
+func (r *PGRepository) ReconcileBalances(ctx context.Context, fix bool) ([]model.BalanceDrift, error) {
+	query := `SELECT u.id,
+	  COALESCE(b.current, 0), COALESCE(b.withdrawn, 0),
+	  COALESCE(l.current, 0), COALESCE(l.withdrawn, 0)
+	FROM users u
+	LEFT JOIN balances b ON b.user_id = u.id
+	LEFT JOIN (
+	  SELECT user_id,
+	    SUM(CASE WHEN account = $1 AND direction = $3 THEN amount
+	             WHEN account = $1 AND direction = $4 THEN -amount ELSE 0 END) AS current,
+	    SUM(CASE WHEN account = $2 AND direction = $3 THEN amount ELSE 0 END) AS withdrawn
+	  FROM ledger_entries
+	  GROUP BY user_id
+	) l ON l.user_id = u.id
+	WHERE COALESCE(b.current, 0) <> COALESCE(l.current, 0)
+	   OR COALESCE(b.withdrawn, 0) <> COALESCE(l.withdrawn, 0)
+	ORDER BY u.id`
+
+	rows, err := r.db.QueryContext(ctx, query,
+		model.LedgerAccountUser, model.LedgerAccountWithdrawals,
+		model.LedgerDirectionCredit, model.LedgerDirectionDebit)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		if err = rows.Close(); err != nil {
+			logger.FromContext(ctx).Info("failed to close rows", zap.Error(err))
+		}
+	}(rows)
+
+	var drifts []model.BalanceDrift
+	for rows.Next() {
+		var drift model.BalanceDrift
+		if err = rows.Scan(&drift.UserID,
+			&drift.Stored.Current, &drift.Stored.Withdrawn,
+			&drift.Expected.Current, &drift.Expected.Withdrawn); err != nil {
+			return nil, err
+		}
+		drifts = append(drifts, drift)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if !fix {
+		return drifts, nil
+	}
+
+	for i := range drifts {
+		err = r.withTx(ctx, func(tx *sql.Tx) error {
+			return recomputeBalance(ctx, tx, &drifts[i])
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return drifts, nil
+}
+
+func (r *PGRepository) RecomputeBalance(ctx context.Context, userID int) (*model.BalanceDrift, error) {
+	drift := model.BalanceDrift{UserID: userID}
+	err := r.withTx(ctx, func(tx *sql.Tx) error {
+		return recomputeBalance(ctx, tx, &drift)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &drift, nil
+}
+
+func recomputeBalance(ctx context.Context, q querier, drift *model.BalanceDrift) error {
+	stored, err := lockBalance(ctx, q, drift.UserID)
+	if err != nil {
+		return err
+	}
+
+	expected, err := getLedgerBalance(ctx, q, drift.UserID)
+	if err != nil {
+		return err
+	}
+	drift.Stored, drift.Expected = *stored, *expected
+
+	_, err = q.ExecContext(ctx,
+		"UPDATE balances SET current = $1, withdrawn = $2, updated_at = now() WHERE user_id = $3",
+		expected.Current, expected.Withdrawn, drift.UserID)
+	return err
+}
+
+func lockBalance(ctx context.Context, q querier, userID int) (*model.Balance, error) {
+	_, err := q.ExecContext(ctx,
+		"INSERT INTO balances (user_id) VALUES ($1) ON CONFLICT (user_id) DO NOTHING", userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var balance model.Balance
+	err = q.QueryRowContext(ctx,
+		"SELECT current, withdrawn FROM balances WHERE user_id = $1 FOR UPDATE",
+		userID).Scan(&balance.Current, &balance.Withdrawn)
+	if err != nil {
+		return nil, err
+	}
+
+	return &balance, nil
+}
+
+func getLedgerBalance(ctx context.Context, q querier, userID int) (*model.Balance, error) {
+	var balance model.Balance
+
+	query := `SELECT
+	  COALESCE(SUM(CASE WHEN account = $2 AND direction = $4 THEN amount
+	                    WHEN account = $2 AND direction = $5 THEN -amount END), 0) AS current,
+	  COALESCE(SUM(CASE WHEN account = $3 AND direction = $4 THEN amount END), 0) AS withdrawn
+	FROM ledger_entries
+	WHERE user_id = $1`
+
+	err := q.QueryRowContext(ctx, query,
+		userID, model.LedgerAccountUser, model.LedgerAccountWithdrawals,
+		model.LedgerDirectionCredit, model.LedgerDirectionDebit,
+	).Scan(&balance.Current, &balance.Withdrawn)
+	if err != nil {
+		return nil, err
+	}
+
+	return &balance, nil
+}
+
+func postTransfer(ctx context.Context, q querier, entry model.LedgerEntry, from, to model.LedgerAccount) error {
+	query := `INSERT INTO ledger_entries (user_id, kind, account, direction, amount, order_number)
+		VALUES ($1, $2, $3, $5, $7, $8), ($1, $2, $4, $6, $7, $8)`
+	_, err := q.ExecContext(ctx, query,
+		entry.UserID, entry.Kind, from, to,
+		model.LedgerDirectionDebit, model.LedgerDirectionCredit,
+		entry.Amount, entry.OrderNumber,
+	)
+	if err != nil {
+		return err
+	}
+
+	var current, withdrawn model.Amount
+	if from == model.LedgerAccountUser {
+		current -= entry.Amount
+	}
+	if to == model.LedgerAccountUser {
+		current += entry.Amount
+	}
+	if to == model.LedgerAccountWithdrawals {
+		withdrawn += entry.Amount
+	}
+
+	_, err = q.ExecContext(ctx, `INSERT INTO balances (user_id, current, withdrawn) VALUES ($1, $2, $3)
+		ON CONFLICT (user_id) DO UPDATE SET
+		  current = balances.current + EXCLUDED.current,
+		  withdrawn = balances.withdrawn + EXCLUDED.withdrawn,
+		  updated_at = now()`,
+		entry.UserID, current, withdrawn)
+	return err
+}
@@ -0,0 +1,100 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/invinciblewest/gophermart/internal/model"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCreateWithdrawalConcurrently(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+
+	suffix := time.Now().UnixNano()
+	user := model.User{Login: fmt.Sprintf("withdraw-%d", suffix), Password: "hash"}
+	if err := repo.CreateUser(ctx, &user); err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+
+	number := fmt.Sprintf("%d", suffix)
+	if err := repo.AddOrder(ctx, &model.Order{Number: number, UserID: user.ID, Status: model.OrderStatusNew}); err != nil {
+		t.Fatalf("AddOrder() error = %v", err)
+	}
+	accrual := model.Amount(1000)
+	err := repo.UpdateOrderStatus(ctx, model.OrderStatusUpdate{
+		Number:  number,
+		Status:  model.OrderStatusProcessed,
+		Accrual: &accrual,
+		Source:  model.OrderEventSourceAdmin,
+	})
+	if err != nil {
+		t.Fatalf("UpdateOrderStatus() error = %v", err)
+	}
+
+	const workers = 20
+	const amount = model.Amount(300)
+	errs := make([]error, workers)
+
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			errs[i] = repo.CreateWithdrawal(ctx, &model.Withdrawal{
+				UserID:      user.ID,
+				OrderNumber: fmt.Sprintf("%s%02d", number, i),
+				Amount:      amount,
+			})
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	withdrawn := 0
+	for i, err := range errs {
+		switch {
+		case err == nil:
+			withdrawn++
+		case errors.Is(err, model.ErrInvalidWithdrawSum):
+		default:
+			t.Errorf("worker %d: CreateWithdrawal() error = %v", i, err)
+		}
+	}
+	if want := int(accrual / amount); withdrawn != want {
+		t.Errorf("%d withdrawals succeeded, want %d", withdrawn, want)
+	}
+
+	balance, err := repo.GetBalanceByUser(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("GetBalanceByUser() error = %v", err)
+	}
+	want := model.Balance{Current: accrual - model.Amount(withdrawn)*amount, Withdrawn: model.Amount(withdrawn) * amount}
+	if balance.Current < 0 || *balance != want {
+		t.Errorf("balance = %+v, want %+v", *balance, want)
+	}
+
+	ledger, err := getLedgerBalance(ctx, repo.db, user.ID)
+	if err != nil {
+		t.Fatalf("getLedgerBalance() error = %v", err)
+	}
+	if *ledger != *balance {
+		t.Errorf("ledger balance = %+v, stored balance = %+v", *ledger, *balance)
+	}
+
+	var net model.Amount
+	err = repo.db.QueryRowContext(ctx,
+		"SELECT COALESCE(SUM(CASE WHEN direction = $2 THEN amount ELSE -amount END), 0) FROM ledger_entries WHERE user_id = $1",
+		user.ID, model.LedgerDirectionCredit).Scan(&net)
+	if err != nil {
+		t.Fatalf("failed to sum ledger entries: %v", err)
+	}
+	if net != 0 {
+		t.Errorf("ledger entries of the user net to %d, want 0", net)
+	}
+}
@@ -0,0 +1,56 @@
+package postgres
+
+import (
+	"fmt"
+	"github.com/invinciblewest/gophermart/internal/model"
+	"github.com/lib/pq"
+	"strings"
+)
+
+func buildListQuery(base string, timeColumn string, args []any, query model.ListQuery) (string, []any) {
+	var conditions []string
+	arg := func(value any) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if len(query.Statuses) > 0 {
+		statuses := make([]string, 0, len(query.Statuses))
+		for _, status := range query.Statuses {
+			statuses = append(statuses, string(status))
+		}
+		conditions = append(conditions, "status = ANY("+arg(pq.Array(statuses))+")")
+	}
+
+	if query.From != nil {
+		conditions = append(conditions, timeColumn+" >= "+arg(*query.From))
+	}
+
+	if query.To != nil {
+		conditions = append(conditions, timeColumn+" < "+arg(*query.To))
+	}
+
+	direction, comparison := "DESC", "<"
+	if query.Ascending {
+		direction, comparison = "ASC", ">"
+	}
+
+	if query.Cursor != nil {
+		conditions = append(conditions, fmt.Sprintf("(%s, id) %s (%s, %s)",
+			timeColumn, comparison, arg(query.Cursor.Time), arg(query.Cursor.ID)))
+	}
+
+	var sb strings.Builder
+	sb.WriteString(base)
+	for _, condition := range conditions {
+		sb.WriteString(" AND ")
+		sb.WriteString(condition)
+	}
+	fmt.Fprintf(&sb, " ORDER BY %s %s, id %s", timeColumn, direction, direction)
+
+	if query.Limit > 0 {
+		sb.WriteString(" LIMIT " + arg(query.Limit+1))
+	}
+
+	return sb.String(), args
+}
@@ -4,30 +4,156 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"github.com/invinciblewest/gophermart/internal/logger"
 	"github.com/invinciblewest/gophermart/internal/model"
+	"github.com/lib/pq"
 	"go.uber.org/zap"
 )
 
 func (r *PGRepository) AddOrder(ctx context.Context, order *model.Order) error {
-	err := r.db.QueryRowContext(ctx,
-		"INSERT INTO orders (number, user_id, status, accrual) VALUES ($1, $2, $3, $4) RETURNING id, uploaded_at",
-		order.Number, order.UserID, order.Status, order.Accrual).Scan(&order.ID, &order.UploadedAt)
+	return r.withTx(ctx, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx,
+			`INSERT INTO orders (number, user_id, status, accrual) VALUES ($1, $2, $3, $4)
+			ON CONFLICT (number) DO NOTHING
+			RETURNING id, uploaded_at`,
+			order.Number, order.UserID, order.Status, order.Accrual).Scan(&order.ID, &order.UploadedAt)
+		if errors.Is(err, sql.ErrNoRows) {
+			return existingOrderError(ctx, tx, order)
+		}
+		if err != nil {
+			return err
+		}
+
+		err = insertOrderEvent(ctx, tx, nil, model.OrderStatusUpdate{
+			Number: order.Number,
+			Status: order.Status,
+			Source: model.OrderEventSourceUser,
+		})
+		if err != nil {
+			return err
+		}
+
+		return enqueueAccrualJob(ctx, tx, order.Number)
+	})
+}
+
+func existingOrderError(ctx context.Context, q querier, order *model.Order) error {
+	var ownerID int
+	err := q.QueryRowContext(ctx, "SELECT user_id FROM orders WHERE number = $1", order.Number).Scan(&ownerID)
 	if err != nil {
 		return err
 	}
-	return nil
+
+	if ownerID == order.UserID {
+		return model.ErrOrderAlreadyExists
+	}
+	return model.ErrOrderAlreadyExistsForAnotherUser
 }
 
-func (r *PGRepository) GetOrderByUser(ctx context.Context, userID int) ([]model.Order, error) {
-	rows, err := r.db.QueryContext(ctx,
-		"SELECT id, number, user_id, status, accrual, uploaded_at FROM orders WHERE user_id = $1", userID)
+func (r *PGRepository) AddOrders(ctx context.Context, userID int, numbers []string) (map[string]model.OrderBatchStatus, error) {
+	results := make(map[string]model.OrderBatchStatus, len(numbers))
+
+	err := r.withTx(ctx, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx,
+			`INSERT INTO orders (number, user_id, status)
+			SELECT number, $2, $3 FROM unnest($1::varchar[]) AS number
+			ON CONFLICT (number) DO NOTHING
+			RETURNING number`,
+			pq.Array(numbers), userID, model.OrderStatusNew)
+		if err != nil {
+			return err
+		}
+
+		var inserted []string
+		for rows.Next() {
+			var number string
+			if err = rows.Scan(&number); err != nil {
+				_ = rows.Close()
+				return err
+			}
+			inserted = append(inserted, number)
+			results[number] = model.OrderBatchAccepted
+		}
+		if err = rows.Close(); err != nil {
+			return err
+		}
+		if err = rows.Err(); err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO order_events (order_number, to_status, source)
+			SELECT number, $2, $3 FROM unnest($1::varchar[]) AS number`,
+			pq.Array(inserted), model.OrderStatusNew, model.OrderEventSourceUser)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO accrual_jobs (order_number)
+			SELECT number FROM unnest($1::varchar[]) AS number
+			ON CONFLICT (order_number) DO NOTHING`,
+			pq.Array(inserted))
+		if err != nil {
+			return err
+		}
+
+		var existing []string
+		for _, number := range numbers {
+			if _, ok := results[number]; !ok {
+				existing = append(existing, number)
+			}
+		}
+		if len(existing) == 0 {
+			return nil
+		}
+
+		rows, err = tx.QueryContext(ctx,
+			"SELECT number, user_id FROM orders WHERE number = ANY($1::varchar[])",
+			pq.Array(existing))
+		if err != nil {
+			return err
+		}
+		defer func(rows *sql.Rows) {
+			if err = rows.Close(); err != nil {
+				logger.FromContext(ctx).Info("failed to close rows", zap.Error(err))
+			}
+		}(rows)
+
+		for rows.Next() {
+			var number string
+			var ownerID int
+			if err = rows.Scan(&number, &ownerID); err != nil {
+				return err
+			}
+			if ownerID == userID {
+				results[number] = model.OrderBatchAlreadyYours
+			} else {
+				results[number] = model.OrderBatchOwnedByAnotherUser
+			}
+		}
+
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+func (r *PGRepository) GetOrderByUser(ctx context.Context, userID int, listQuery model.ListQuery) ([]model.Order, error) {
+	query, args := buildListQuery(
+		"SELECT id, number, user_id, status, accrual, uploaded_at FROM orders WHERE user_id = $1",
+		"uploaded_at", []any{userID}, listQuery)
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer func(rows *sql.Rows) {
 		if err = rows.Close(); err != nil {
-			logger.Log.Info("failed to close rows", zap.Error(err))
+			logger.FromContext(ctx).Info("failed to close rows", zap.Error(err))
 		}
 	}(rows)
 
@@ -65,45 +191,167 @@ func (r *PGRepository) GetOrderByNumber(ctx context.Context, number string) (*mo
 	return &order, nil
 }
 
-func (r *PGRepository) UpdateOrderStatus(ctx context.Context, number string, status model.OrderStatus, accrual *model.Amount) error {
-	_, err := r.db.ExecContext(ctx,
-		"UPDATE orders SET status = $1, accrual = $2 WHERE number = $3",
-		status, accrual, number)
-	if err != nil {
+func (r *PGRepository) UpdateOrderStatus(ctx context.Context, update model.OrderStatusUpdate) error {
+	return r.withTx(ctx, func(tx *sql.Tx) error {
+		if update.LeaseToken != "" {
+			if err := lockAccrualJob(ctx, tx, update.LeaseToken, update.Number); err != nil {
+				return err
+			}
+		} else if err := lockAccrualJobRow(ctx, tx, update.Number); err != nil {
+			return err
+		}
+
+		var userID int
+		var currentStatus model.OrderStatus
+		var currentAccrual *model.Amount
+		var currentReason string
+		err := tx.QueryRowContext(ctx,
+			"SELECT user_id, status, accrual, COALESCE(status_reason, '') FROM orders WHERE number = $1 FOR UPDATE",
+			update.Number).Scan(&userID, &currentStatus, &currentAccrual, &currentReason)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return model.ErrOrderNotFound
+			}
+			return err
+		}
+
+		if !currentStatus.CanTransitionTo(update.Status) {
+			return fmt.Errorf("%w: %s -> %s", model.ErrInvalidStatusTransition, currentStatus, update.Status)
+		}
+
+		if currentStatus == update.Status && currentReason == update.Reason &&
+			equalAmounts(currentAccrual, update.Accrual) {
+			return nil
+		}
+
+		_, err = tx.ExecContext(ctx,
+			"UPDATE orders SET status = $1, accrual = $2, status_reason = NULLIF($3, '') WHERE number = $4",
+			update.Status, update.Accrual, update.Reason, update.Number)
+		if err != nil {
+			return err
+		}
+
+		if err = insertOrderEvent(ctx, tx, &currentStatus, update); err != nil {
+			return err
+		}
+
+		if update.Status.IsFinal() {
+			if err = completeAccrualJob(ctx, tx, update.Number); err != nil {
+				return err
+			}
+		}
+
+		if currentStatus == model.OrderStatusProcessed || update.Status != model.OrderStatusProcessed ||
+			update.Accrual == nil || *update.Accrual <= 0 {
+			return nil
+		}
+
+		return postTransfer(ctx, tx, model.LedgerEntry{
+			UserID:      userID,
+			Kind:        model.LedgerKindAccrual,
+			Amount:      *update.Accrual,
+			OrderNumber: update.Number,
+		}, model.LedgerAccountAccruals, model.LedgerAccountUser)
+	})
+}
+
+func (r *PGRepository) RequeueOrder(ctx context.Context, number string) error {
+	return r.withTx(ctx, func(tx *sql.Tx) error {
+		if err := lockAccrualJobRow(ctx, tx, number); err != nil {
+			return err
+		}
+
+		var currentStatus model.OrderStatus
+		err := tx.QueryRowContext(ctx,
+			"SELECT status FROM orders WHERE number = $1 FOR UPDATE", number).Scan(&currentStatus)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return model.ErrOrderNotFound
+			}
+			return err
+		}
+
+		if currentStatus == model.OrderStatusProcessed {
+			return fmt.Errorf("%w: %s orders cannot be requeued", model.ErrInvalidStatusTransition, currentStatus)
+		}
+
+		if currentStatus == model.OrderStatusInvalid {
+			_, err = tx.ExecContext(ctx,
+				"UPDATE orders SET status = $1, accrual = NULL, status_reason = NULL WHERE number = $2",
+				model.OrderStatusNew, number)
+			if err != nil {
+				return err
+			}
+
+			err = insertOrderEvent(ctx, tx, &currentStatus, model.OrderStatusUpdate{
+				Number: number,
+				Status: model.OrderStatusNew,
+				Reason: "requeued by admin",
+				Source: model.OrderEventSourceAdmin,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO accrual_jobs (order_number) VALUES ($1)
+			ON CONFLICT (order_number) DO UPDATE
+			SET attempts = 0, next_attempt_at = now(), locked_by = NULL, locked_until = NULL, lease_token = NULL,
+			    last_error = NULL, created_at = now(), updated_at = now()`,
+			number)
 		return err
-	}
-	return nil
+	})
 }
 
-func (r *PGRepository) GetPendingOrders(ctx context.Context) ([]model.Order, error) {
+func (r *PGRepository) GetOrderEvents(ctx context.Context, number string) ([]model.OrderEvent, error) {
 	rows, err := r.db.QueryContext(ctx,
-		`SELECT id, number, user_id, status, accrual, uploaded_at FROM orders WHERE status IN ($1, $2)`,
-		model.OrderStatusNew, model.OrderStatusProcessing)
+		`SELECT id, order_number, from_status, to_status, accrual, COALESCE(reason, ''), source, raw_response, created_at
+		FROM order_events WHERE order_number = $1 ORDER BY created_at, id`, number)
 	if err != nil {
 		return nil, err
 	}
 	defer func(rows *sql.Rows) {
 		if err = rows.Close(); err != nil {
-			logger.Log.Info("failed to close rows", zap.Error(err))
+			logger.FromContext(ctx).Info("failed to close rows", zap.Error(err))
 		}
 	}(rows)
 
-	var orders []model.Order
+	var events []model.OrderEvent
 	for rows.Next() {
-		var order model.Order
-		if err = rows.Scan(&order.ID, &order.Number, &order.UserID, &order.Status, &order.Accrual, &order.UploadedAt); err != nil {
+		var event model.OrderEvent
+		var raw []byte
+		if err = rows.Scan(&event.ID, &event.OrderNumber, &event.FromStatus, &event.ToStatus, &event.Accrual,
+			&event.Reason, &event.Source, &raw, &event.CreatedAt); err != nil {
 			return nil, err
 		}
-		orders = append(orders, order)
+		event.RawResponse = raw
+		events = append(events, event)
 	}
 
 	if err = rows.Err(); err != nil {
 		return nil, err
 	}
 
-	if len(orders) == 0 {
-		return nil, model.ErrOrderNotFound
+	return events, nil
+}
+
+func insertOrderEvent(ctx context.Context, q querier, from *model.OrderStatus, update model.OrderStatusUpdate) error {
+	var raw any
+	if len(update.RawResponse) > 0 {
+		raw = string(update.RawResponse)
 	}
 
-	return orders, nil
+	_, err := q.ExecContext(ctx,
+		`INSERT INTO order_events (order_number, from_status, to_status, accrual, reason, source, raw_response)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7)`,
+		update.Number, from, update.Status, update.Accrual, update.Reason, update.Source, raw)
+	return err
+}
+
+func equalAmounts(a, b *model.Amount) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
 }
@@ -0,0 +1,107 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/invinciblewest/gophermart/internal/model"
+	"github.com/invinciblewest/gophermart/migrations"
+	"github.com/pressly/goose"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestRepository(t *testing.T) *PGRepository {
+	t.Helper()
+
+	databaseURI := os.Getenv("DATABASE_URI")
+	if databaseURI == "" {
+		t.Skip("DATABASE_URI is not set")
+	}
+
+	db, err := sql.Open("postgres", databaseURI)
+	if err != nil {
+		t.Fatalf("sql.Open() error = %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	if err = goose.SetDialect("postgres"); err != nil {
+		t.Fatalf("goose.SetDialect() error = %v", err)
+	}
+	migrationsDir, err := migrations.Extract()
+	if err != nil {
+		t.Fatalf("migrations.Extract() error = %v", err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(migrationsDir) })
+	if err = goose.Up(db, migrationsDir); err != nil {
+		t.Fatalf("goose.Up() error = %v", err)
+	}
+	return NewPGRepository(db)
+}
+
+func TestAddOrderConcurrently(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+
+	suffix := time.Now().UnixNano()
+	users := make([]model.User, 2)
+	for i := range users {
+		users[i] = model.User{Login: fmt.Sprintf("concurrent-%d-%d", suffix, i), Password: "hash"}
+		if err := repo.CreateUser(ctx, &users[i]); err != nil {
+			t.Fatalf("CreateUser() error = %v", err)
+		}
+	}
+
+	const workers = 20
+	number := fmt.Sprintf("%d", suffix)
+	owners := make([]int, workers)
+	errs := make([]error, workers)
+
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			owners[i] = users[i%2].ID
+			errs[i] = repo.AddOrder(ctx, &model.Order{Number: number, UserID: owners[i], Status: model.OrderStatusNew})
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	order, err := repo.GetOrderByNumber(ctx, number)
+	if err != nil {
+		t.Fatalf("GetOrderByNumber() error = %v", err)
+	}
+
+	inserted := 0
+	for i, err := range errs {
+		switch {
+		case err == nil:
+			inserted++
+			if owners[i] != order.UserID {
+				t.Errorf("worker %d inserted the order for user %d, but it belongs to user %d", i, owners[i], order.UserID)
+			}
+		case owners[i] == order.UserID && errors.Is(err, model.ErrOrderAlreadyExists):
+		case owners[i] != order.UserID && errors.Is(err, model.ErrOrderAlreadyExistsForAnotherUser):
+		default:
+			t.Errorf("worker %d for user %d: AddOrder() error = %v", i, owners[i], err)
+		}
+	}
+	if inserted != 1 {
+		t.Errorf("%d inserts succeeded, want exactly 1", inserted)
+	}
+
+	events, err := repo.GetOrderEvents(ctx, number)
+	if err != nil {
+		t.Fatalf("GetOrderEvents() error = %v", err)
+	}
+	if len(events) != 1 {
+		t.Errorf("order has %d events, want 1", len(events))
+	}
+}
@@ -1,9 +1,18 @@
 package postgres
 
 import (
+	"context"
 	"database/sql"
+	"github.com/invinciblewest/gophermart/internal/logger"
+	"go.uber.org/zap"
 )
 
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
 type PGRepository struct {
 	db *sql.DB
 }
@@ -13,3 +22,19 @@ func NewPGRepository(db *sql.DB) *PGRepository {
 		db: db,
 	}
 }
+
+func (r *PGRepository) withTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err = fn(tx); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			logger.FromContext(ctx).Info("failed to rollback transaction", zap.Error(rbErr))
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,103 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/invinciblewest/gophermart/internal/model"
+	"time"
+)
+
+func (r *PGRepository) CreateRefreshToken(ctx context.Context, token *model.RefreshToken) error {
+	return r.db.QueryRowContext(ctx,
+		`INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at)
+		VALUES ($1, $2, $3, $4) RETURNING id, created_at`,
+		token.UserID, token.FamilyID, token.TokenHash, token.ExpiresAt,
+	).Scan(&token.ID, &token.CreatedAt)
+}
+
+func (r *PGRepository) GetRefreshToken(ctx context.Context, tokenHash string) (*model.RefreshToken, error) {
+	var token model.RefreshToken
+	err := r.db.QueryRowContext(ctx,
+		`SELECT id, user_id, family_id, token_hash, expires_at, used_at, revoked_at, created_at
+		FROM refresh_tokens WHERE token_hash = $1`,
+		tokenHash).Scan(&token.ID, &token.UserID, &token.FamilyID, &token.TokenHash,
+		&token.ExpiresAt, &token.UsedAt, &token.RevokedAt, &token.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, model.ErrInvalidToken
+		}
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (r *PGRepository) MarkRefreshTokenUsed(ctx context.Context, id int) (bool, error) {
+	result, err := r.db.ExecContext(ctx,
+		"UPDATE refresh_tokens SET used_at = now() WHERE id = $1 AND used_at IS NULL AND revoked_at IS NULL",
+		id)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected == 1, nil
+}
+
+func (r *PGRepository) RevokeTokenFamily(ctx context.Context, familyID string) error {
+	_, err := r.db.ExecContext(ctx,
+		"UPDATE refresh_tokens SET revoked_at = now() WHERE family_id = $1 AND revoked_at IS NULL",
+		familyID)
+	return err
+}
+
+func (r *PGRepository) RevokeUserTokens(ctx context.Context, userID int) error {
+	_, err := r.db.ExecContext(ctx,
+		"UPDATE refresh_tokens SET revoked_at = now() WHERE user_id = $1 AND revoked_at IS NULL", userID)
+	return err
+}
+
+func (r *PGRepository) RevokeAccessToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	_, err := r.db.ExecContext(ctx,
+		"INSERT INTO revoked_tokens (jti, expires_at) VALUES ($1, $2) ON CONFLICT (jti) DO NOTHING",
+		jti, expiresAt)
+	return err
+}
+
+func (r *PGRepository) IsAccessTokenRevoked(ctx context.Context, jti string, userID int) (bool, error) {
+	var revoked bool
+	err := r.db.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = $1)
+		OR EXISTS (SELECT 1 FROM users WHERE id = $2 AND disabled_at IS NOT NULL)`,
+		jti, userID).Scan(&revoked)
+	if err != nil {
+		return false, err
+	}
+	return revoked, nil
+}
+
+func (r *PGRepository) PurgeExpiredTokens(ctx context.Context) (int64, error) {
+	var deleted int64
+	err := r.withTx(ctx, func(tx *sql.Tx) error {
+		for _, query := range []string{
+			"DELETE FROM revoked_tokens WHERE expires_at < now()",
+			"DELETE FROM refresh_tokens WHERE expires_at < now()",
+		} {
+			result, err := tx.ExecContext(ctx, query)
+			if err != nil {
+				return err
+			}
+			affected, err := result.RowsAffected()
+			if err != nil {
+				return err
+			}
+			deleted += affected
+		}
+		return nil
+	})
+	return deleted, err
+}
@@ -4,9 +4,11 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"github.com/invinciblewest/gophermart/internal/logger"
 	"github.com/invinciblewest/gophermart/internal/model"
 	"github.com/jackc/pgerrcode"
 	"github.com/lib/pq"
+	"go.uber.org/zap"
 )
 
 func (r *PGRepository) CreateUser(ctx context.Context, user *model.User) error {
@@ -40,8 +42,8 @@ func (r *PGRepository) GetUserByLogin(ctx context.Context, login string) (*model
 	var user model.User
 
 	err := r.db.QueryRowContext(ctx,
-		`SELECT id, login, password, created_at FROM users WHERE login = $1`,
-		login).Scan(&user.ID, &user.Login, &user.Password, &user.CreatedAt)
+		`SELECT id, login, password, created_at, disabled_at FROM users WHERE login = $1`,
+		login).Scan(&user.ID, &user.Login, &user.Password, &user.CreatedAt, &user.DisabledAt)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, model.ErrUserNotFound
@@ -51,3 +53,43 @@ func (r *PGRepository) GetUserByLogin(ctx context.Context, login string) (*model
 
 	return &user, nil
 }
+
+func (r *PGRepository) UpdatePassword(ctx context.Context, userID int, password string) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE users SET password = $1 WHERE id = $2", password, userID)
+	return err
+}
+
+func (r *PGRepository) DisableUser(ctx context.Context, userID int) error {
+	_, err := r.db.ExecContext(ctx,
+		"UPDATE users SET disabled_at = COALESCE(disabled_at, now()) WHERE id = $1", userID)
+	return err
+}
+
+func (r *PGRepository) ListUsers(ctx context.Context, afterID int, limit int) ([]model.User, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT id, login, password, created_at, disabled_at FROM users WHERE id > $1 ORDER BY id LIMIT $2`,
+		afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		if err = rows.Close(); err != nil {
+			logger.FromContext(ctx).Info("failed to close rows", zap.Error(err))
+		}
+	}(rows)
+
+	var users []model.User
+	for rows.Next() {
+		var user model.User
+		if err = rows.Scan(&user.ID, &user.Login, &user.Password, &user.CreatedAt, &user.DisabledAt); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
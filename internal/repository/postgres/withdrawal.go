@@ -8,27 +8,17 @@ import (
 	"go.uber.org/zap"
 )
 
-func (r *PGRepository) CreateWithdrawal(ctx context.Context, withdrawal *model.Withdrawal) error {
-	query := `INSERT INTO withdrawals (user_id, order_number, amount)
-		VALUES ($1, $2, $3) RETURNING id, processed_at`
-	err := r.db.QueryRowContext(ctx, query,
-		withdrawal.UserID, withdrawal.OrderNumber, withdrawal.Amount,
-	).Scan(&withdrawal.ID, &withdrawal.ProcessedAt)
-	if err != nil {
-		return err
-	}
-	return nil
-}
-
-func (r *PGRepository) GetWithdrawalByUser(ctx context.Context, userID int) ([]model.Withdrawal, error) {
-	query := `SELECT id, user_id, order_number, amount, processed_at FROM withdrawals WHERE user_id = $1`
-	rows, err := r.db.QueryContext(ctx, query, userID)
+func (r *PGRepository) GetWithdrawalByUser(ctx context.Context, userID int, listQuery model.ListQuery) ([]model.Withdrawal, error) {
+	query, args := buildListQuery(
+		"SELECT id, user_id, order_number, amount, processed_at FROM withdrawals WHERE user_id = $1",
+		"processed_at", []any{userID}, listQuery)
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer func(rows *sql.Rows) {
 		if err = rows.Close(); err != nil {
-			logger.Log.Info("failed to close rows", zap.Error(err))
+			logger.FromContext(ctx).Info("failed to close rows", zap.Error(err))
 		}
 	}(rows)
 
@@ -42,13 +32,13 @@ func (r *PGRepository) GetWithdrawalByUser(ctx context.Context, userID int) ([]m
 		withdrawals = append(withdrawals, withdrawal)
 	}
 
-	if len(withdrawals) == 0 {
-		return nil, model.ErrWithdrawalNotFound
-	}
-
 	if err = rows.Err(); err != nil {
 		return nil, err
 	}
 
+	if len(withdrawals) == 0 {
+		return nil, model.ErrWithdrawalNotFound
+	}
+
 	return withdrawals, nil
 }
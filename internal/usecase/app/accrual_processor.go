@@ -3,29 +3,68 @@ package app
 import (
 	"context"
 	"errors"
+	"fmt"
 	"github.com/invinciblewest/gophermart/internal/client/accrual"
 	"github.com/invinciblewest/gophermart/internal/logger"
+	"github.com/invinciblewest/gophermart/internal/metrics"
 	"github.com/invinciblewest/gophermart/internal/model"
 	"github.com/invinciblewest/gophermart/internal/repository"
+	"github.com/invinciblewest/gophermart/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
 	"go.uber.org/zap"
+	"math/rand/v2"
+	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+type AccrualProcessorOptions struct {
+	BatchSize   int
+	Lease       time.Duration
+	BackoffBase time.Duration
+	BackoffMax  time.Duration
+	MaxAge      time.Duration
+	Timeout     time.Duration
+}
+
 type AccrualProcessor struct {
-	orderRepository repository.OrderRepository
-	accrualClient   *accrual.Client
-	workerCount     int
+	orderRepository    repository.OrderRepository
+	jobRepository      repository.AccrualJobRepository
+	accrualClient      *accrual.Client
+	workerID           string
+	options            AccrualProcessorOptions
+	pausedUntil        atomic.Int64
+	invalidTransitions atomic.Uint64
+	lastTick           atomic.Int64
+	stop               chan struct{}
+	stopOnce           sync.Once
+	stopped            chan struct{}
 }
 
-func NewAccrualProcessor(orderRepository repository.OrderRepository, accrualClient *accrual.Client) *AccrualProcessor {
+func NewAccrualProcessor(
+	orderRepository repository.OrderRepository,
+	jobRepository repository.AccrualJobRepository,
+	accrualClient *accrual.Client,
+	options AccrualProcessorOptions,
+) *AccrualProcessor {
 	return &AccrualProcessor{
 		orderRepository: orderRepository,
+		jobRepository:   jobRepository,
 		accrualClient:   accrualClient,
+		workerID:        newWorkerID(),
+		options:         options,
+		stop:            make(chan struct{}),
+		stopped:         make(chan struct{}),
 	}
 }
 
 func (p *AccrualProcessor) Run(ctx context.Context, interval int, workerCount int) {
+	defer close(p.stopped)
+
+	metrics.AccrualWorkers.Set(float64(workerCount))
+	p.lastTick.Store(time.Now().UnixNano())
+
 	ticker := time.NewTicker(time.Duration(interval) * time.Second)
 	defer ticker.Stop()
 
@@ -33,88 +72,329 @@ func (p *AccrualProcessor) Run(ctx context.Context, interval int, workerCount in
 		select {
 		case <-ctx.Done():
 			return
+		case <-p.stop:
+			return
 		case <-ticker.C:
 			p.processPendingOrders(ctx, workerCount)
+			p.updateQueueDepth(ctx)
+			p.lastTick.Store(time.Now().UnixNano())
 		}
 	}
 }
 
 func (p *AccrualProcessor) processPendingOrders(ctx context.Context, workerCount int) {
-	orders, err := p.orderRepository.GetPendingOrders(ctx)
-	if err != nil {
-		if errors.Is(err, model.ErrOrderNotFound) {
-			logger.Log.Info("no pending orders found")
-			return
-		}
-		logger.Log.Error("failed to get pending orders", zap.Error(err))
+	ctx = logger.With(ctx, zap.String("worker_id", p.workerID))
+
+	if until, paused := p.paused(); paused {
+		logger.FromContext(ctx).Info("accrual polling is paused", zap.Time("until", until))
 		return
 	}
 
-	jobs := make(chan model.Order)
-	var wg sync.WaitGroup
+	var budget, processed atomic.Int64
+	budget.Store(int64(p.options.BatchSize))
 
+	var wg sync.WaitGroup
 	for i := 0; i < workerCount; i++ {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			for order := range jobs {
-				select {
-				case <-ctx.Done():
-					return
-				default:
-					p.processOrder(ctx, order)
-				}
-			}
+			processed.Add(p.work(ctx, &budget))
 		}()
 	}
+	wg.Wait()
+
+	if processed.Load() == 0 {
+		logger.FromContext(ctx).Info("no pending orders found")
+	}
+}
 
-	go func() {
-		defer close(jobs)
-		for _, order := range orders {
-			select {
-			case jobs <- order:
-			case <-ctx.Done():
-				return
-			}
+func (p *AccrualProcessor) work(ctx context.Context, budget *atomic.Int64) int64 {
+	var processed int64
+	for ctx.Err() == nil && !p.stopping() && budget.Add(-1) >= 0 {
+		if _, paused := p.paused(); paused {
+			break
 		}
-	}()
 
-	wg.Wait()
+		jobs, err := p.jobRepository.ClaimAccrualJobs(ctx, p.workerID, 1, p.options.Lease)
+		if err != nil {
+			logger.FromContext(ctx).Error("failed to claim accrual jobs", zap.Error(err))
+			break
+		}
+		if len(jobs) == 0 {
+			break
+		}
+
+		metrics.AccrualWorkersBusy.Inc()
+		p.processOrder(ctx, jobs[0])
+		metrics.AccrualWorkersBusy.Dec()
+		processed++
+	}
+	return processed
+}
+
+func (p *AccrualProcessor) Stop(ctx context.Context) error {
+	p.stopOnce.Do(func() { close(p.stop) })
+
+	select {
+	case <-p.stopped:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("accrual workers did not finish: %w", ctx.Err())
+	}
 }
 
-func (p *AccrualProcessor) processOrder(ctx context.Context, order model.Order) {
-	reqCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
-	defer cancel()
+func (p *AccrualProcessor) processOrder(ctx context.Context, job model.AccrualJob) {
+	ctx, span := startSpan(ctx, "AccrualProcessor.processOrder",
+		attribute.String("order.number", job.OrderNumber), attribute.Int("accrual.attempts", job.Attempts))
+	defer span.End()
+
+	if until, paused := p.paused(); paused {
+		p.release(ctx, job, until)
+		return
+	}
+
+	if p.options.MaxAge > 0 && time.Since(job.CreatedAt) > p.options.MaxAge {
+		p.expire(ctx, job)
+		return
+	}
+
+	reqCtx := ctx
+	if p.options.Timeout > 0 {
+		var cancel context.CancelFunc
+		reqCtx, cancel = context.WithTimeout(ctx, p.options.Timeout)
+		defer cancel()
+	}
 
-	response, retryAfter, err := p.accrualClient.GetOrderInfo(reqCtx, order.Number)
+	response, retryAfter, err := p.accrualClient.GetOrderInfo(reqCtx, job.OrderNumber)
 	if err != nil {
-		logger.Log.Info("failed to get order info", zap.String("order_number", order.Number), zap.Error(err))
+		tracing.RecordError(span, err)
+		logger.FromContext(ctx).Info("failed to get order info", zap.String("order_number", job.OrderNumber), zap.Error(err))
+		p.reschedule(ctx, job, err.Error())
 		return
 	}
 
 	if retryAfter > 0 {
-		logger.Log.Info("accrual service is busy, retrying later",
-			zap.String("order_number", order.Number), zap.Int("retry_after", retryAfter))
-		select {
-		case <-ctx.Done():
+		until := p.pause(time.Duration(retryAfter) * time.Second)
+		logger.FromContext(ctx).Info("accrual service is busy, pausing all workers",
+			zap.String("order_number", job.OrderNumber), zap.Int("retry_after", retryAfter), zap.Time("until", until))
+		p.release(ctx, job, until)
+		return
+	}
+
+	if response == nil {
+		logger.FromContext(ctx).Info("empty response from accrual service", zap.String("order_number", job.OrderNumber))
+		p.reschedule(ctx, job, "order is not registered in accrual service")
+		return
+	}
+
+	status, ok := response.Status.OrderStatus()
+	if !ok {
+		p.rejectTransition(ctx, job, fmt.Errorf("%w: %q", model.ErrUnknownAccrualStatus, response.Status))
+		return
+	}
+
+	order, err := p.orderRepository.GetOrderByNumber(ctx, job.OrderNumber)
+	if err != nil {
+		logger.FromContext(ctx).Info("failed to get order", zap.String("order_number", job.OrderNumber), zap.Error(err))
+		p.reschedule(ctx, job, err.Error())
+		return
+	}
+
+	if order.Status.IsFinal() {
+		p.complete(ctx, job)
+		return
+	}
+
+	span.SetAttributes(attribute.String("order.status", string(order.Status)), attribute.String("accrual.status", string(status)))
+
+	if !order.Status.CanTransitionTo(status) {
+		p.rejectTransition(ctx, job,
+			fmt.Errorf("%w: %s -> %s", model.ErrInvalidStatusTransition, order.Status, status))
+		return
+	}
+
+	update := model.OrderStatusUpdate{
+		Number:      job.OrderNumber,
+		Status:      status,
+		Source:      model.OrderEventSourcePoller,
+		RawResponse: response.Raw,
+		LeaseToken:  job.LeaseToken,
+	}
+	if status == model.OrderStatusProcessed {
+		update.Accrual = &response.Accrual
+	}
+
+	if err = p.orderRepository.UpdateOrderStatus(ctx, update); err != nil {
+		if errors.Is(err, model.ErrAccrualLeaseLost) {
+			p.leaseLost(ctx, job)
 			return
-		case <-time.After(time.Duration(retryAfter) * time.Second):
+		}
+		if errors.Is(err, model.ErrInvalidStatusTransition) {
+			p.rejectTransition(ctx, job, err)
 			return
 		}
+		tracing.RecordError(span, err)
+		logger.FromContext(ctx).Info("failed to update order accrual", zap.String("order_number", job.OrderNumber), zap.Error(err))
+		p.reschedule(ctx, job, err.Error())
+		return
 	}
 
-	if response == nil {
-		logger.Log.Info("empty response from accrual service", zap.String("order_number", order.Number))
+	if !status.IsFinal() {
+		p.reschedule(ctx, job, "")
 		return
 	}
+	metrics.AccrualJobs.WithLabelValues("completed").Inc()
+}
 
-	var newAccrual *model.Amount
-	if response.Status == model.OrderStatusProcessed {
-		newAccrual = &response.Accrual
+func (p *AccrualProcessor) LastTick() time.Time {
+	if tick := p.lastTick.Load(); tick != 0 {
+		return time.Unix(0, tick)
 	}
+	return time.Time{}
+}
+
+func (p *AccrualProcessor) InvalidTransitions() uint64 {
+	return p.invalidTransitions.Load()
+}
+
+func (p *AccrualProcessor) rejectTransition(ctx context.Context, job model.AccrualJob, err error) {
+	p.invalidTransitions.Add(1)
+	metrics.AccrualInvalidTransitions.Inc()
+	logger.FromContext(ctx).Warn("rejected accrual status update", zap.String("order_number", job.OrderNumber), zap.Error(err))
+	p.reschedule(ctx, job, err.Error())
+}
+
+func (p *AccrualProcessor) complete(ctx context.Context, job model.AccrualJob) {
+	if err := p.jobRepository.CompleteAccrualJob(ctx, job.LeaseToken, job.OrderNumber); err != nil {
+		if errors.Is(err, model.ErrAccrualLeaseLost) {
+			p.leaseLost(ctx, job)
+			return
+		}
+		logger.FromContext(ctx).Info("failed to complete accrual job", zap.String("order_number", job.OrderNumber), zap.Error(err))
+		return
+	}
+	metrics.AccrualJobs.WithLabelValues("completed").Inc()
+}
+
+func (p *AccrualProcessor) expire(ctx context.Context, job model.AccrualJob) {
+	reason := fmt.Sprintf("accrual was not received within %s after %d attempts", p.options.MaxAge, job.Attempts)
+	if job.LastError != "" {
+		reason = fmt.Sprintf("%s, last error: %s", reason, job.LastError)
+	}
+
+	logger.FromContext(ctx).Warn("accrual job expired",
+		zap.String("order_number", job.OrderNumber), zap.Int("attempts", job.Attempts), zap.String("reason", reason))
 
-	if err = p.orderRepository.UpdateOrderStatus(ctx, order.Number, response.Status, newAccrual); err != nil {
-		logger.Log.Info("failed to update order accrual", zap.String("order_number", order.Number), zap.Error(err))
+	err := p.orderRepository.UpdateOrderStatus(ctx, model.OrderStatusUpdate{
+		Number:     job.OrderNumber,
+		Status:     model.OrderStatusInvalid,
+		Reason:     reason,
+		Source:     model.OrderEventSourcePoller,
+		LeaseToken: job.LeaseToken,
+	})
+	if errors.Is(err, model.ErrAccrualLeaseLost) {
+		p.leaseLost(ctx, job)
 		return
 	}
+	if err != nil {
+		logger.FromContext(ctx).Info("failed to expire order", zap.String("order_number", job.OrderNumber), zap.Error(err))
+		p.reschedule(ctx, job, err.Error())
+		return
+	}
+	metrics.AccrualJobs.WithLabelValues("expired").Inc()
+}
+
+func (p *AccrualProcessor) reschedule(ctx context.Context, job model.AccrualJob, lastError string) {
+	nextAttemptAt := time.Now().Add(p.backoff(job.Attempts))
+	if err := p.jobRepository.RescheduleAccrualJob(ctx, job.LeaseToken, job.OrderNumber, nextAttemptAt, lastError); err != nil {
+		if errors.Is(err, model.ErrAccrualLeaseLost) {
+			p.leaseLost(ctx, job)
+			return
+		}
+		logger.FromContext(ctx).Info("failed to reschedule accrual job", zap.String("order_number", job.OrderNumber), zap.Error(err))
+		return
+	}
+	metrics.AccrualJobs.WithLabelValues("rescheduled").Inc()
+}
+
+func (p *AccrualProcessor) release(ctx context.Context, job model.AccrualJob, nextAttemptAt time.Time) {
+	if err := p.jobRepository.ReleaseAccrualJob(ctx, job.LeaseToken, job.OrderNumber, nextAttemptAt); err != nil {
+		if errors.Is(err, model.ErrAccrualLeaseLost) {
+			p.leaseLost(ctx, job)
+			return
+		}
+		logger.FromContext(ctx).Info("failed to release accrual job", zap.String("order_number", job.OrderNumber), zap.Error(err))
+		return
+	}
+	metrics.AccrualJobs.WithLabelValues("released").Inc()
+}
+
+func (p *AccrualProcessor) leaseLost(ctx context.Context, job model.AccrualJob) {
+	logger.FromContext(ctx).Warn("accrual job lease lost, dropping result", zap.String("order_number", job.OrderNumber))
+	metrics.AccrualJobs.WithLabelValues("lease_lost").Inc()
+}
+
+func (p *AccrualProcessor) stopping() bool {
+	select {
+	case <-p.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+func (p *AccrualProcessor) updateQueueDepth(ctx context.Context) {
+	count, err := p.jobRepository.CountAccrualJobs(ctx)
+	if err != nil {
+		logger.FromContext(ctx).Info("failed to count accrual jobs", zap.Error(err))
+		return
+	}
+	metrics.AccrualPendingJobs.Set(float64(count))
+}
+
+func (p *AccrualProcessor) backoff(attempts int) time.Duration {
+	delay := p.options.BackoffMax
+	if attempts < 32 {
+		if d := p.options.BackoffBase << attempts; d > 0 && d < delay {
+			delay = d
+		}
+	}
+
+	half := int64(delay / 2)
+	if half <= 0 {
+		return delay
+	}
+	return time.Duration(half + rand.Int64N(half+1))
+}
+
+func (p *AccrualProcessor) pause(d time.Duration) time.Time {
+	until := time.Now().Add(d).UnixNano()
+	for {
+		current := p.pausedUntil.Load()
+		if current >= until {
+			return time.Unix(0, current)
+		}
+		if p.pausedUntil.CompareAndSwap(current, until) {
+			return time.Unix(0, until)
+		}
+	}
+}
+
+func (p *AccrualProcessor) paused() (time.Time, bool) {
+	until := time.Unix(0, p.pausedUntil.Load())
+	return until, time.Now().Before(until)
+}
+
+func newWorkerID() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+
+	suffix, err := randomToken(4)
+	if err != nil {
+		return fmt.Sprintf("%s-%d", hostname, os.Getpid())
+	}
+
+	return fmt.Sprintf("%s-%d-%s", hostname, os.Getpid(), suffix)
 }
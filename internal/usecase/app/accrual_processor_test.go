@@ -0,0 +1,283 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"github.com/invinciblewest/gophermart/internal/accrualfake"
+	"github.com/invinciblewest/gophermart/internal/client/accrual"
+	"github.com/invinciblewest/gophermart/internal/model"
+	"github.com/invinciblewest/gophermart/internal/repository"
+	"sync"
+	"testing"
+	"time"
+)
+
+type memoryAccrualStore struct {
+	repository.OrderRepository
+
+	mu     sync.Mutex
+	orders map[string]*model.Order
+	jobs   map[string]*model.AccrualJob
+	claims int
+}
+
+func newMemoryAccrualStore(numbers ...string) *memoryAccrualStore {
+	store := &memoryAccrualStore{
+		orders: make(map[string]*model.Order),
+		jobs:   make(map[string]*model.AccrualJob),
+	}
+	for _, number := range numbers {
+		store.orders[number] = &model.Order{Number: number, Status: model.OrderStatusNew}
+		store.jobs[number] = &model.AccrualJob{OrderNumber: number, CreatedAt: time.Now()}
+	}
+	return store
+}
+
+func (s *memoryAccrualStore) order(number string) model.Order {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return *s.orders[number]
+}
+
+func (s *memoryAccrualStore) job(number string) (model.AccrualJob, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[number]
+	if !ok {
+		return model.AccrualJob{}, false
+	}
+	return *job, true
+}
+
+func (s *memoryAccrualStore) makeDue() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, job := range s.jobs {
+		job.NextAttemptAt = time.Time{}
+	}
+}
+
+func (s *memoryAccrualStore) GetOrderByNumber(_ context.Context, number string) (*model.Order, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	order, ok := s.orders[number]
+	if !ok {
+		return nil, model.ErrOrderNotFound
+	}
+	copied := *order
+	return &copied, nil
+}
+
+func (s *memoryAccrualStore) UpdateOrderStatus(_ context.Context, update model.OrderStatusUpdate) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if update.LeaseToken != "" {
+		if job, ok := s.jobs[update.Number]; !ok || !holdsLease(job, update.LeaseToken) {
+			return model.ErrAccrualLeaseLost
+		}
+	}
+
+	order := s.orders[update.Number]
+	if !order.Status.CanTransitionTo(update.Status) {
+		return fmt.Errorf("%w: %s -> %s", model.ErrInvalidStatusTransition, order.Status, update.Status)
+	}
+	order.Status = update.Status
+	order.Accrual = update.Accrual
+	if update.Status.IsFinal() {
+		delete(s.jobs, update.Number)
+	}
+	return nil
+}
+
+func (s *memoryAccrualStore) ClaimAccrualJobs(_ context.Context, workerID string, limit int, lease time.Duration) ([]model.AccrualJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	var claimed []model.AccrualJob
+	for _, job := range s.jobs {
+		if len(claimed) == limit {
+			break
+		}
+		if job.NextAttemptAt.After(now) || job.LockedUntil.After(now) {
+			continue
+		}
+		s.claims++
+		job.LockedBy = workerID
+		job.LockedUntil = now.Add(lease)
+		job.LeaseToken = fmt.Sprintf("lease-%d", s.claims)
+		claimed = append(claimed, *job)
+	}
+	return claimed, nil
+}
+
+func (s *memoryAccrualStore) RescheduleAccrualJob(_ context.Context, leaseToken string, number string, nextAttemptAt time.Time, lastError string) error {
+	return s.unlock(leaseToken, number, func(job *model.AccrualJob) {
+		job.Attempts++
+		job.NextAttemptAt = nextAttemptAt
+		job.LastError = lastError
+	})
+}
+
+func (s *memoryAccrualStore) ReleaseAccrualJob(_ context.Context, leaseToken string, number string, nextAttemptAt time.Time) error {
+	return s.unlock(leaseToken, number, func(job *model.AccrualJob) {
+		job.NextAttemptAt = nextAttemptAt
+	})
+}
+
+func (s *memoryAccrualStore) CompleteAccrualJob(_ context.Context, leaseToken string, number string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if job, ok := s.jobs[number]; !ok || !holdsLease(job, leaseToken) {
+		return model.ErrAccrualLeaseLost
+	}
+	delete(s.jobs, number)
+	return nil
+}
+
+func (s *memoryAccrualStore) CountAccrualJobs(context.Context) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.jobs), nil
+}
+
+func (s *memoryAccrualStore) unlock(leaseToken string, number string, update func(job *model.AccrualJob)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[number]
+	if !ok || !holdsLease(job, leaseToken) {
+		return model.ErrAccrualLeaseLost
+	}
+	update(job)
+	job.LockedBy = ""
+	job.LockedUntil = time.Time{}
+	job.LeaseToken = ""
+	return nil
+}
+
+func holdsLease(job *model.AccrualJob, leaseToken string) bool {
+	return job.LeaseToken == leaseToken && job.LockedUntil.After(time.Now())
+}
+
+func newTestProcessor(t *testing.T, store *memoryAccrualStore) (*accrualfake.Server, *accrualfake.ManualClock, *AccrualProcessor) {
+	t.Helper()
+
+	clock := accrualfake.NewManualClock(time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC))
+	fake, server := accrualfake.NewTestServer(accrualfake.Options{Now: clock.Now})
+	t.Cleanup(server.Close)
+
+	client := accrual.NewClient(server.URL, accrual.Options{Timeout: time.Second})
+	processor := NewAccrualProcessor(store, store, client, AccrualProcessorOptions{
+		BatchSize:   10,
+		Lease:       time.Minute,
+		BackoffBase: time.Hour,
+		BackoffMax:  time.Hour,
+	})
+	return fake, clock, processor
+}
+
+func TestAccrualProcessorCompletesProcessedOrders(t *testing.T) {
+	store := newMemoryAccrualStore("12345678903", "9278923470")
+	fake, clock, processor := newTestProcessor(t, store)
+	fake.Register("12345678903", 500)
+	fake.Register("9278923470", 0, accrualfake.InvalidSteps...)
+
+	processor.processPendingOrders(context.Background(), 2)
+
+	if status := store.order("12345678903").Status; status != model.OrderStatusProcessing {
+		t.Fatalf("order status after first poll = %s, want %s", status, model.OrderStatusProcessing)
+	}
+	if job, ok := store.job("12345678903"); !ok || job.LockedBy != "" || job.Attempts != 1 {
+		t.Fatalf("job after first poll = %+v, %v, want an unlocked job with one attempt", job, ok)
+	}
+
+	clock.Advance(3 * time.Second)
+	store.makeDue()
+	processor.processPendingOrders(context.Background(), 2)
+
+	processed := store.order("12345678903")
+	if processed.Status != model.OrderStatusProcessed || processed.Accrual == nil || *processed.Accrual != 50000 {
+		t.Errorf("processed order = %s/%v, want %s/50000", processed.Status, processed.Accrual, model.OrderStatusProcessed)
+	}
+	if status := store.order("9278923470").Status; status != model.OrderStatusInvalid {
+		t.Errorf("invalid order status = %s, want %s", status, model.OrderStatusInvalid)
+	}
+	if count, _ := store.CountAccrualJobs(context.Background()); count != 0 {
+		t.Errorf("%d accrual jobs left, want 0", count)
+	}
+}
+
+func TestAccrualProcessorPausesOnThrottling(t *testing.T) {
+	store := newMemoryAccrualStore("12345678903")
+	fake, _, processor := newTestProcessor(t, store)
+	fake.Register("12345678903", 500)
+	fake.ThrottleNext(1, time.Minute)
+
+	processor.processPendingOrders(context.Background(), 1)
+
+	until, paused := processor.paused()
+	if !paused || time.Until(until) < 50*time.Second {
+		t.Fatalf("processor paused = %v until %s, want a pause of about a minute", paused, until)
+	}
+
+	job, ok := store.job("12345678903")
+	if !ok || job.LockedBy != "" || job.Attempts != 0 || !job.NextAttemptAt.Equal(until) {
+		t.Errorf("job after throttling = %+v, want it released until %s without an attempt", job, until)
+	}
+
+	requests := fake.Requests()
+	processor.processPendingOrders(context.Background(), 1)
+	if fake.Requests() != requests {
+		t.Error("paused processor still polled the accrual service")
+	}
+}
+
+func (s *memoryAccrualStore) expireLeases() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, job := range s.jobs {
+		job.LockedUntil = time.Now().Add(-time.Second)
+	}
+}
+
+func TestAccrualProcessorDropsResultAfterLosingLease(t *testing.T) {
+	tests := []struct {
+		name    string
+		reclaim bool
+	}{
+		{name: "lease expired"},
+		{name: "lease re-claimed by the same process", reclaim: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newMemoryAccrualStore("12345678903")
+			fake, _, processor := newTestProcessor(t, store)
+			fake.Register("12345678903", 500, accrualfake.Step{Status: accrualfake.StatusProcessed})
+
+			stale, _ := store.ClaimAccrualJobs(context.Background(), processor.workerID, 1, time.Minute)
+			store.expireLeases()
+			var current []model.AccrualJob
+			if tt.reclaim {
+				current, _ = store.ClaimAccrualJobs(context.Background(), processor.workerID, 1, time.Minute)
+			}
+
+			processor.processOrder(context.Background(), stale[0])
+
+			if status := store.order("12345678903").Status; status != model.OrderStatusNew {
+				t.Errorf("order status = %s, want it untouched as %s", status, model.OrderStatusNew)
+			}
+			job, ok := store.job("12345678903")
+			if !ok {
+				t.Fatal("accrual job was removed by the stale lease holder")
+			}
+			if tt.reclaim && job.LeaseToken != current[0].LeaseToken {
+				t.Errorf("job lease = %q, want it still held by %q", job.LeaseToken, current[0].LeaseToken)
+			}
+			if job.Attempts != 0 {
+				t.Errorf("job attempts = %d, want 0", job.Attempts)
+			}
+		})
+	}
+}
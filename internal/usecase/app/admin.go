@@ -0,0 +1,184 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"github.com/invinciblewest/gophermart/internal/model"
+	"github.com/invinciblewest/gophermart/internal/repository"
+	"github.com/invinciblewest/gophermart/internal/usecase"
+)
+
+const exportPageSize = 100
+
+type AdminUseCase struct {
+	userRepository       repository.UserRepository
+	tokenRepository      repository.TokenRepository
+	orderRepository      repository.OrderRepository
+	withdrawalRepository repository.WithdrawalRepository
+	ledgerRepository     repository.LedgerRepository
+	authUseCase          usecase.AuthUseCase
+}
+
+func NewAdminUseCase(
+	userRepository repository.UserRepository,
+	tokenRepository repository.TokenRepository,
+	orderRepository repository.OrderRepository,
+	withdrawalRepository repository.WithdrawalRepository,
+	ledgerRepository repository.LedgerRepository,
+	authUseCase usecase.AuthUseCase,
+) *AdminUseCase {
+	return &AdminUseCase{
+		userRepository:       userRepository,
+		tokenRepository:      tokenRepository,
+		orderRepository:      orderRepository,
+		withdrawalRepository: withdrawalRepository,
+		ledgerRepository:     ledgerRepository,
+		authUseCase:          authUseCase,
+	}
+}
+
+func (as *AdminUseCase) CreateUser(ctx context.Context, login string, password string) (_ *model.User, err error) {
+	ctx, span := startSpan(ctx, "AdminUseCase.CreateUser")
+	defer func() { endSpan(span, err) }()
+
+	if login == "" || password == "" {
+		return nil, model.ErrEmptyLoginOrPassword
+	}
+
+	hash, err := as.authUseCase.HashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &model.User{Login: login, Password: hash}
+	if err = as.userRepository.CreateUser(ctx, user); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (as *AdminUseCase) DisableUser(ctx context.Context, login string) (err error) {
+	ctx, span := startSpan(ctx, "AdminUseCase.DisableUser")
+	defer func() { endSpan(span, err) }()
+
+	user, err := as.userRepository.GetUserByLogin(ctx, login)
+	if err != nil {
+		return err
+	}
+
+	if err = as.userRepository.DisableUser(ctx, user.ID); err != nil {
+		return err
+	}
+
+	return as.tokenRepository.RevokeUserTokens(ctx, user.ID)
+}
+
+func (as *AdminUseCase) ResetPassword(ctx context.Context, login string, password string) (err error) {
+	ctx, span := startSpan(ctx, "AdminUseCase.ResetPassword")
+	defer func() { endSpan(span, err) }()
+
+	if password == "" {
+		return model.ErrEmptyLoginOrPassword
+	}
+
+	user, err := as.userRepository.GetUserByLogin(ctx, login)
+	if err != nil {
+		return err
+	}
+
+	hash, err := as.authUseCase.HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	if err = as.userRepository.UpdatePassword(ctx, user.ID, hash); err != nil {
+		return err
+	}
+
+	return as.tokenRepository.RevokeUserTokens(ctx, user.ID)
+}
+
+func (as *AdminUseCase) RequeueOrder(ctx context.Context, number string) (err error) {
+	ctx, span := startSpan(ctx, "AdminUseCase.RequeueOrder")
+	defer func() { endSpan(span, err) }()
+
+	return as.orderRepository.RequeueOrder(ctx, number)
+}
+
+func (as *AdminUseCase) RecomputeBalance(ctx context.Context, login string) (_ *model.BalanceDrift, err error) {
+	ctx, span := startSpan(ctx, "AdminUseCase.RecomputeBalance")
+	defer func() { endSpan(span, err) }()
+
+	user, err := as.userRepository.GetUserByLogin(ctx, login)
+	if err != nil {
+		return nil, err
+	}
+
+	return as.ledgerRepository.RecomputeBalance(ctx, user.ID)
+}
+
+func (as *AdminUseCase) ReconcileBalances(ctx context.Context, fix bool) (_ []model.BalanceDrift, err error) {
+	ctx, span := startSpan(ctx, "AdminUseCase.ReconcileBalances")
+	defer func() { endSpan(span, err) }()
+
+	return as.ledgerRepository.ReconcileBalances(ctx, fix)
+}
+
+func (as *AdminUseCase) Export(ctx context.Context, fn func(model.UserExport) error) (err error) {
+	ctx, span := startSpan(ctx, "AdminUseCase.Export")
+	defer func() { endSpan(span, err) }()
+
+	afterID := 0
+	for {
+		users, err := as.userRepository.ListUsers(ctx, afterID, exportPageSize)
+		if err != nil {
+			return err
+		}
+
+		for _, user := range users {
+			export, err := as.exportUser(ctx, user)
+			if err != nil {
+				return err
+			}
+			if err = fn(*export); err != nil {
+				return err
+			}
+			afterID = user.ID
+		}
+
+		if len(users) < exportPageSize {
+			return nil
+		}
+	}
+}
+
+func (as *AdminUseCase) exportUser(ctx context.Context, user model.User) (*model.UserExport, error) {
+	balance, err := as.ledgerRepository.GetBalanceByUser(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	orders, err := as.orderRepository.GetOrderByUser(ctx, user.ID, model.ListQuery{Ascending: true})
+	if err != nil && !errors.Is(err, model.ErrOrderNotFound) {
+		return nil, err
+	}
+
+	withdrawals, err := as.withdrawalRepository.GetWithdrawalByUser(ctx, user.ID, model.ListQuery{Ascending: true})
+	if err != nil && !errors.Is(err, model.ErrWithdrawalNotFound) {
+		return nil, err
+	}
+
+	export := &model.UserExport{
+		Login:       user.Login,
+		CreatedAt:   user.CreatedAt,
+		DisabledAt:  user.DisabledAt,
+		Balance:     *balance,
+		Orders:      []model.Order{},
+		Withdrawals: []model.Withdrawal{},
+	}
+	export.Orders = append(export.Orders, orders...)
+	export.Withdrawals = append(export.Withdrawals, withdrawals...)
+
+	return export, nil
+}
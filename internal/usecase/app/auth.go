@@ -1,67 +1,308 @@
 package app
 
 import (
+	"context"
 	"crypto/hmac"
+	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/invinciblewest/gophermart/internal/keyset"
 	"github.com/invinciblewest/gophermart/internal/logger"
 	"github.com/invinciblewest/gophermart/internal/model"
+	"github.com/invinciblewest/gophermart/internal/repository"
 	"go.uber.org/zap"
 	"time"
 )
 
 type AuthUseCase struct {
-	secretKey string
+	secretKey       string
+	keySet          *keyset.KeySet
+	tokenRepository repository.TokenRepository
+	accessTTL       time.Duration
+	refreshTTL      time.Duration
 }
 
-func NewAuthUseCase(secretKey string) *AuthUseCase {
+func NewAuthUseCase(
+	secretKey string,
+	keySet *keyset.KeySet,
+	tokenRepository repository.TokenRepository,
+	accessTTL time.Duration,
+	refreshTTL time.Duration,
+) *AuthUseCase {
 	return &AuthUseCase{
-		secretKey: secretKey,
+		secretKey:       secretKey,
+		keySet:          keySet,
+		tokenRepository: tokenRepository,
+		accessTTL:       accessTTL,
+		refreshTTL:      refreshTTL,
 	}
 }
 
-func (as *AuthUseCase) GenerateToken(userID int) (string, error) {
+func (as *AuthUseCase) IssueTokens(ctx context.Context, userID int) (*model.TokenPair, error) {
+	familyID, err := randomToken(16)
+	if err != nil {
+		return nil, err
+	}
+	return as.issueTokens(ctx, userID, familyID)
+}
+
+func (as *AuthUseCase) RefreshTokens(ctx context.Context, refreshToken string) (*model.TokenPair, error) {
+	token, err := as.tokenRepository.GetRefreshToken(ctx, hashToken(refreshToken))
+	if err != nil {
+		return nil, err
+	}
+
+	if token.RevokedAt != nil {
+		return nil, model.ErrTokenRevoked
+	}
+
+	if token.UsedAt != nil {
+		return nil, as.revokeReusedFamily(ctx, token)
+	}
+
+	if time.Now().After(token.ExpiresAt) {
+		return nil, model.ErrInvalidToken
+	}
+
+	ok, err := as.tokenRepository.MarkRefreshTokenUsed(ctx, token.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, as.revokeReusedFamily(ctx, token)
+	}
+
+	return as.issueTokens(ctx, token.UserID, token.FamilyID)
+}
+
+func (as *AuthUseCase) RevokeTokens(ctx context.Context, claims *model.TokenClaims, refreshToken string) error {
+	if err := as.tokenRepository.RevokeAccessToken(ctx, claims.ID, claims.ExpiresAt); err != nil {
+		return err
+	}
+
+	if claims.FamilyID != "" {
+		if err := as.tokenRepository.RevokeTokenFamily(ctx, claims.FamilyID); err != nil {
+			return err
+		}
+	}
+
+	if refreshToken == "" {
+		return nil
+	}
+
+	token, err := as.tokenRepository.GetRefreshToken(ctx, hashToken(refreshToken))
+	if err != nil {
+		return err
+	}
+
+	if token.UserID != claims.UserID {
+		return model.ErrInvalidToken
+	}
+
+	return as.tokenRepository.RevokeTokenFamily(ctx, token.FamilyID)
+}
+
+func (as *AuthUseCase) ParseToken(ctx context.Context, tokenStr string) (*model.TokenClaims, error) {
+	token, err := jwt.Parse(tokenStr, as.verificationKey, jwt.WithValidMethods(as.validMethods()))
+
+	if err != nil || !token.Valid {
+		logger.FromContext(ctx).Debug("failed to parse token", zap.Error(err))
+		return nil, model.ErrInvalidToken
+	}
+
+	mapClaims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, fmt.Errorf("%w: invalid claims", model.ErrInvalidToken)
+	}
+
+	userIDFloat, ok := mapClaims["user_id"].(float64)
+	if !ok {
+		return nil, fmt.Errorf("%w: user_id not found", model.ErrInvalidToken)
+	}
+
+	jti, ok := mapClaims["jti"].(string)
+	if !ok || jti == "" {
+		return nil, fmt.Errorf("%w: jti not found", model.ErrInvalidToken)
+	}
+
+	familyID, _ := mapClaims["fid"].(string)
+
+	exp, err := mapClaims.GetExpirationTime()
+	if err != nil || exp == nil {
+		return nil, model.ErrInvalidToken
+	}
+
+	userID := int(userIDFloat)
+	revoked, err := as.tokenRepository.IsAccessTokenRevoked(ctx, jti, userID)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, model.ErrTokenRevoked
+	}
+
+	return &model.TokenClaims{
+		ID:        jti,
+		UserID:    userID,
+		FamilyID:  familyID,
+		ExpiresAt: exp.Time,
+	}, nil
+}
+
+func (as *AuthUseCase) PurgeExpiredTokens(ctx context.Context) (int64, error) {
+	return as.tokenRepository.PurgeExpiredTokens(ctx)
+}
+
+func (as *AuthUseCase) JWKS() model.JWKS {
+	if as.keySet == nil {
+		return model.JWKS{Keys: []model.JWK{}}
+	}
+	return as.keySet.JWKS()
+}
+
+func (as *AuthUseCase) HashPassword(password string) (string, error) {
+	return hashArgon2id(password, defaultArgon2Params)
+}
+
+func (as *AuthUseCase) VerifyPassword(ctx context.Context, user *model.User, password string) bool {
+	if !isArgon2idHash(user.Password) {
+		return hmac.Equal([]byte(user.Password), []byte(as.legacyHashPassword(password)))
+	}
+
+	ok, err := verifyArgon2id(user.Password, password)
+	if err != nil {
+		logger.FromContext(ctx).Error("failed to verify password hash", zap.Int("user_id", user.ID), zap.Error(err))
+		return false
+	}
+	return ok
+}
+
+func (as *AuthUseCase) NeedsRehash(hash string) bool {
+	if !isArgon2idHash(hash) {
+		return true
+	}
+
+	params, _, _, err := decodeArgon2id(hash)
+	if err != nil {
+		return true
+	}
+	return params != defaultArgon2Params
+}
+
+func (as *AuthUseCase) issueTokens(ctx context.Context, userID int, familyID string) (*model.TokenPair, error) {
+	accessToken, err := as.generateAccessToken(userID, familyID)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	err = as.tokenRepository.CreateRefreshToken(ctx, &model.RefreshToken{
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: hashToken(refreshToken),
+		ExpiresAt: time.Now().Add(as.refreshTTL),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(as.accessTTL.Seconds()),
+	}, nil
+}
+
+func (as *AuthUseCase) generateAccessToken(userID int, familyID string) (string, error) {
+	jti, err := randomToken(16)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
 	claims := jwt.MapClaims{
 		"user_id": userID,
-		"exp":     time.Now().Add(24 * time.Hour).Unix(),
-		"iat":     time.Now().Unix(),
+		"jti":     jti,
+		"fid":     familyID,
+		"exp":     now.Add(as.accessTTL).Unix(),
+		"iat":     now.Unix(),
+	}
+
+	if as.keySet == nil {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		return token.SignedString([]byte(as.secretKey))
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(as.secretKey))
+
+	key := as.keySet.SigningKey()
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(key.Algorithm), claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.Private)
 }
 
-func (as *AuthUseCase) ParseToken(tokenStr string) (int, error) {
-	token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
+func (as *AuthUseCase) verificationKey(token *jwt.Token) (interface{}, error) {
+	if as.keySet == nil {
 		return []byte(as.secretKey), nil
-	})
-
-	if err != nil || !token.Valid {
-		logger.Log.Error("failed to parse token", zap.Error(err))
-		return 0, errors.New("invalid token")
 	}
 
-	claims, ok := token.Claims.(jwt.MapClaims)
+	kid, ok := token.Header["kid"].(string)
 	if !ok {
-		return 0, errors.New("invalid claims")
+		return nil, errors.New("kid not found")
 	}
 
-	userIDFloat, ok := claims["user_id"].(float64)
+	key, ok := as.keySet.VerificationKey(kid)
 	if !ok {
-		return 0, errors.New("user_id not found")
+		return nil, fmt.Errorf("unknown kid %q", kid)
+	}
+
+	if token.Method.Alg() != key.Algorithm {
+		return nil, fmt.Errorf("unexpected signing method %q for kid %q", token.Method.Alg(), kid)
 	}
 
-	return int(userIDFloat), nil
+	return key.Public, nil
+}
+
+func (as *AuthUseCase) validMethods() []string {
+	if as.keySet == nil {
+		return []string{jwt.SigningMethodHS256.Alg()}
+	}
+	return as.keySet.Algorithms()
 }
 
-func (as *AuthUseCase) HashPassword(password string) string {
+func (as *AuthUseCase) legacyHashPassword(password string) string {
 	hash := hmac.New(sha256.New, []byte(as.secretKey))
 	hash.Write([]byte(password))
 	return base64.StdEncoding.EncodeToString(hash.Sum(nil))
 }
 
-func (as *AuthUseCase) VerifyPassword(user *model.User, password string) bool {
-	hashedPassword := as.HashPassword(password)
-	return hmac.Equal([]byte(user.Password), []byte(hashedPassword))
+func (as *AuthUseCase) revokeReusedFamily(ctx context.Context, token *model.RefreshToken) error {
+	logger.FromContext(ctx).Warn("refresh token reuse detected, revoking family",
+		zap.Int("user_id", token.UserID), zap.String("family_id", token.FamilyID))
+
+	if err := as.tokenRepository.RevokeTokenFamily(ctx, token.FamilyID); err != nil {
+		return fmt.Errorf("failed to revoke token family: %w", err)
+	}
+
+	return model.ErrRefreshTokenReused
+}
+
+func randomToken(size int) (string, error) {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
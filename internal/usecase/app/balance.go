@@ -2,43 +2,53 @@ package app
 
 import (
 	"context"
-	"github.com/invinciblewest/gophermart/internal/helper"
+	"github.com/invinciblewest/gophermart/internal/metrics"
 	"github.com/invinciblewest/gophermart/internal/model"
 	"github.com/invinciblewest/gophermart/internal/repository"
+	"github.com/invinciblewest/gophermart/internal/validator"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type BalanceUseCase struct {
-	balanceRepository    repository.BalanceRepository
+	ledgerRepository     repository.LedgerRepository
 	withdrawalRepository repository.WithdrawalRepository
+	numberValidator      validator.OrderNumberValidator
 }
 
-func NewBalanceUseCase(balanceRepository repository.BalanceRepository, withdrawalRepository repository.WithdrawalRepository) *BalanceUseCase {
+func NewBalanceUseCase(
+	ledgerRepository repository.LedgerRepository,
+	withdrawalRepository repository.WithdrawalRepository,
+	numberValidator validator.OrderNumberValidator,
+) *BalanceUseCase {
 	return &BalanceUseCase{
-		balanceRepository:    balanceRepository,
+		ledgerRepository:     ledgerRepository,
 		withdrawalRepository: withdrawalRepository,
+		numberValidator:      numberValidator,
 	}
 }
 
-func (b *BalanceUseCase) GetUserBalance(ctx context.Context, userID int) (*model.Balance, error) {
-	balance, err := b.balanceRepository.GetBalanceByUser(ctx, userID)
+func (b *BalanceUseCase) GetUserBalance(ctx context.Context, userID int) (_ *model.Balance, err error) {
+	ctx, span := startSpan(ctx, "BalanceUseCase.GetUserBalance", attribute.Int("user.id", userID))
+	defer func() { endSpan(span, err) }()
+
+	balance, err := b.ledgerRepository.GetBalanceByUser(ctx, userID)
 	if err != nil {
 		return nil, err
 	}
 	return balance, nil
 }
 
-func (b *BalanceUseCase) WithdrawBalance(ctx context.Context, userID int, withdrawRequest model.WithdrawRequest) error {
-	if !helper.IsValidOrderNumber(withdrawRequest.Order) {
-		return model.ErrInvalidOrderNumber
-	}
+func (b *BalanceUseCase) WithdrawBalance(ctx context.Context, userID int, withdrawRequest model.WithdrawRequest) (err error) {
+	ctx, span := startSpan(ctx, "BalanceUseCase.WithdrawBalance",
+		attribute.Int("user.id", userID), attribute.String("order.number", withdrawRequest.Order))
+	defer func() { endSpan(span, err) }()
 
-	balance, err := b.GetUserBalance(ctx, userID)
-	if err != nil {
+	if err = b.numberValidator.Validate(withdrawRequest.Order); err != nil {
 		return err
 	}
 
-	if balance.Current < withdrawRequest.Sum {
-		return model.ErrInvalidWithdrawSum
+	if withdrawRequest.Sum <= 0 {
+		return model.ErrInvalidWithdrawAmount
 	}
 
 	withdrawal := &model.Withdrawal{
@@ -47,18 +57,30 @@ func (b *BalanceUseCase) WithdrawBalance(ctx context.Context, userID int, withdr
 		Amount:      withdrawRequest.Sum,
 	}
 
-	if err = b.withdrawalRepository.CreateWithdrawal(ctx, withdrawal); err != nil {
+	if err = b.ledgerRepository.CreateWithdrawal(ctx, withdrawal); err != nil {
 		return err
 	}
 
+	metrics.Withdrawals.Inc()
+	metrics.WithdrawnPoints.Add(float64(withdrawal.Amount) / 100)
+
 	return nil
 }
 
-func (b *BalanceUseCase) GetWithdrawals(ctx context.Context, userID int) ([]model.Withdrawal, error) {
-	withdrawals, err := b.withdrawalRepository.GetWithdrawalByUser(ctx, userID)
+func (b *BalanceUseCase) GetWithdrawals(ctx context.Context, userID int, query model.ListQuery) (_ []model.Withdrawal, _ string, err error) {
+	ctx, span := startSpan(ctx, "BalanceUseCase.GetWithdrawals", attribute.Int("user.id", userID))
+	defer func() { endSpan(span, err) }()
+
+	withdrawals, err := b.withdrawalRepository.GetWithdrawalByUser(ctx, userID, query)
 	if err != nil {
-		return nil, err
+		return nil, "", err
+	}
+
+	if query.Limit <= 0 || len(withdrawals) <= query.Limit {
+		return withdrawals, "", nil
 	}
 
-	return withdrawals, nil
+	withdrawals = withdrawals[:query.Limit]
+	last := withdrawals[len(withdrawals)-1]
+	return withdrawals, model.Cursor{Time: last.ProcessedAt, ID: last.ID}.Encode(), nil
 }
@@ -0,0 +1,151 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/invinciblewest/gophermart/internal/client/accrual"
+	"github.com/invinciblewest/gophermart/internal/model"
+	"github.com/invinciblewest/gophermart/internal/repository"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const healthCheckTimeout = 2 * time.Second
+
+var errShuttingDown = errors.New("server is shutting down")
+
+type healthCheck struct {
+	name string
+	run  func(ctx context.Context) (map[string]any, error)
+}
+
+type HealthUseCase struct {
+	healthRepository repository.HealthRepository
+	accrualClient    *accrual.Client
+	accrualProcessor *AccrualProcessor
+	migrationVersion int64
+	maxTickAge       time.Duration
+	shuttingDown     atomic.Bool
+}
+
+func NewHealthUseCase(
+	healthRepository repository.HealthRepository,
+	accrualClient *accrual.Client,
+	accrualProcessor *AccrualProcessor,
+	migrationVersion int64,
+	maxTickAge time.Duration,
+) *HealthUseCase {
+	return &HealthUseCase{
+		healthRepository: healthRepository,
+		accrualClient:    accrualClient,
+		accrualProcessor: accrualProcessor,
+		migrationVersion: migrationVersion,
+		maxTickAge:       maxTickAge,
+	}
+}
+
+func (hs *HealthUseCase) Live() model.HealthReport {
+	return model.HealthReport{Status: model.HealthStatusOK}
+}
+
+func (hs *HealthUseCase) Ready(ctx context.Context) model.HealthReport {
+	checks := []healthCheck{
+		{name: "shutdown", run: hs.checkShutdown},
+		{name: "database", run: hs.checkDatabase},
+		{name: "migrations", run: hs.checkMigrations},
+		{name: "accrual", run: hs.checkAccrual},
+		{name: "accrual_processor", run: hs.checkAccrualProcessor},
+	}
+
+	report := model.HealthReport{
+		Status: model.HealthStatusOK,
+		Checks: make([]model.HealthCheck, len(checks)),
+	}
+
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			report.Checks[i] = runHealthCheck(ctx, check)
+		}()
+	}
+	wg.Wait()
+
+	for _, check := range report.Checks {
+		if check.Status != model.HealthStatusOK {
+			report.Status = model.HealthStatusFail
+		}
+	}
+
+	return report
+}
+
+func (hs *HealthUseCase) SetShuttingDown() {
+	hs.shuttingDown.Store(true)
+}
+
+func (hs *HealthUseCase) checkShutdown(context.Context) (map[string]any, error) {
+	if hs.shuttingDown.Load() {
+		return nil, errShuttingDown
+	}
+	return nil, nil
+}
+
+func (hs *HealthUseCase) checkDatabase(ctx context.Context) (map[string]any, error) {
+	return nil, hs.healthRepository.Ping(ctx)
+}
+
+func (hs *HealthUseCase) checkMigrations(ctx context.Context) (map[string]any, error) {
+	version, err := hs.healthRepository.GetMigrationVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	details := map[string]any{"current": version, "expected": hs.migrationVersion}
+	if version < hs.migrationVersion {
+		return details, fmt.Errorf("database is at migration %d, expected %d", version, hs.migrationVersion)
+	}
+	return details, nil
+}
+
+func (hs *HealthUseCase) checkAccrual(ctx context.Context) (map[string]any, error) {
+	details := map[string]any{"breaker": hs.accrualClient.BreakerState().String()}
+	return details, hs.accrualClient.Ping(ctx)
+}
+
+func (hs *HealthUseCase) checkAccrualProcessor(context.Context) (map[string]any, error) {
+	lastTick := hs.accrualProcessor.LastTick()
+	if lastTick.IsZero() {
+		return nil, errors.New("accrual processor has not started")
+	}
+
+	age := time.Since(lastTick)
+	details := map[string]any{"last_tick": lastTick, "age_seconds": age.Seconds()}
+	if age > hs.maxTickAge {
+		return details, fmt.Errorf("accrual processor has not ticked for %s", age.Round(time.Second))
+	}
+	return details, nil
+}
+
+func runHealthCheck(ctx context.Context, check healthCheck) model.HealthCheck {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	start := time.Now()
+	details, err := check.run(ctx)
+
+	result := model.HealthCheck{
+		Name:       check.name,
+		Status:     model.HealthStatusOK,
+		DurationMs: float64(time.Since(start).Microseconds()) / 1000,
+		Details:    details,
+	}
+	if err != nil {
+		result.Status = model.HealthStatusFail
+		result.Error = err.Error()
+	}
+	return result
+}
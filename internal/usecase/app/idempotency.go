@@ -0,0 +1,68 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"github.com/invinciblewest/gophermart/internal/model"
+	"github.com/invinciblewest/gophermart/internal/repository"
+	"time"
+)
+
+type IdempotencyUseCase struct {
+	idempotencyRepository repository.IdempotencyRepository
+	lease                 time.Duration
+	ttl                   time.Duration
+}
+
+func NewIdempotencyUseCase(
+	idempotencyRepository repository.IdempotencyRepository,
+	lease time.Duration,
+	ttl time.Duration,
+) *IdempotencyUseCase {
+	return &IdempotencyUseCase{
+		idempotencyRepository: idempotencyRepository,
+		lease:                 lease,
+		ttl:                   ttl,
+	}
+}
+
+func (is *IdempotencyUseCase) Reserve(ctx context.Context, record *model.IdempotencyRecord) (*model.IdempotencyRecord, error) {
+	err := is.idempotencyRepository.CreateIdempotencyKey(ctx, record, is.lease)
+	if err == nil {
+		return nil, nil
+	}
+	if !errors.Is(err, model.ErrIdempotencyKeyExists) {
+		return nil, err
+	}
+
+	stored, err := is.idempotencyRepository.GetIdempotencyKey(ctx, record.UserID, record.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	if stored.RequestHash != record.RequestHash {
+		return nil, model.ErrIdempotencyKeyReused
+	}
+
+	if !stored.Completed() {
+		return nil, model.ErrIdempotencyKeyInProgress
+	}
+
+	return stored, nil
+}
+
+func (is *IdempotencyUseCase) Complete(ctx context.Context, record *model.IdempotencyRecord) error {
+	return is.idempotencyRepository.SaveIdempotencyResponse(ctx, record)
+}
+
+func (is *IdempotencyUseCase) Release(ctx context.Context, record *model.IdempotencyRecord) error {
+	return is.idempotencyRepository.DeleteIdempotencyKey(ctx, record.UserID, record.Key)
+}
+
+func (is *IdempotencyUseCase) Block(ctx context.Context, record *model.IdempotencyRecord) error {
+	return is.idempotencyRepository.LockIdempotencyKey(ctx, record.UserID, record.Key, time.Now().Add(is.ttl))
+}
+
+func (is *IdempotencyUseCase) Purge(ctx context.Context) (int64, error) {
+	return is.idempotencyRepository.PurgeIdempotencyKeys(ctx, time.Now().Add(-is.ttl))
+}
@@ -0,0 +1,80 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"github.com/invinciblewest/gophermart/internal/logger"
+	"go.uber.org/zap"
+	"sync"
+	"time"
+)
+
+type JanitorTask func(ctx context.Context) (int64, error)
+
+type namedJanitorTask struct {
+	name string
+	run  JanitorTask
+}
+
+type Janitor struct {
+	interval time.Duration
+	tasks    []namedJanitorTask
+	stop     chan struct{}
+	stopOnce sync.Once
+	stopped  chan struct{}
+}
+
+func NewJanitor(interval time.Duration) *Janitor {
+	return &Janitor{
+		interval: interval,
+		stop:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+}
+
+func (j *Janitor) Add(name string, task JanitorTask) {
+	j.tasks = append(j.tasks, namedJanitorTask{name: name, run: task})
+}
+
+func (j *Janitor) Run(ctx context.Context) {
+	defer close(j.stopped)
+
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		j.runTasks(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-j.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (j *Janitor) Stop(ctx context.Context) error {
+	j.stopOnce.Do(func() { close(j.stop) })
+
+	select {
+	case <-j.stopped:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("janitor did not finish: %w", ctx.Err())
+	}
+}
+
+func (j *Janitor) runTasks(ctx context.Context) {
+	for _, task := range j.tasks {
+		deleted, err := task.run(ctx)
+		if err != nil {
+			logger.FromContext(ctx).Error("janitor task failed", zap.String("task", task.name), zap.Error(err))
+			continue
+		}
+		if deleted > 0 {
+			logger.FromContext(ctx).Info("janitor task finished", zap.String("task", task.name), zap.Int64("deleted", deleted))
+		}
+	}
+}
@@ -3,39 +3,39 @@ package app
 import (
 	"context"
 	"errors"
-	"github.com/invinciblewest/gophermart/internal/helper"
 	"github.com/invinciblewest/gophermart/internal/model"
 	"github.com/invinciblewest/gophermart/internal/repository"
+	"github.com/invinciblewest/gophermart/internal/validator"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type OrderUseCase struct {
 	OrderRepository repository.OrderRepository
+	numberValidator validator.OrderNumberValidator
+	batchMaxSize    int
 }
 
-func NewOrderUseCase(orderRepository repository.OrderRepository) *OrderUseCase {
+func NewOrderUseCase(
+	orderRepository repository.OrderRepository,
+	numberValidator validator.OrderNumberValidator,
+	batchMaxSize int,
+) *OrderUseCase {
 	return &OrderUseCase{
 		OrderRepository: orderRepository,
+		numberValidator: numberValidator,
+		batchMaxSize:    batchMaxSize,
 	}
 }
 
-func (os *OrderUseCase) AddOrder(ctx context.Context, order *model.Order) error {
-	if !helper.IsValidOrderNumber(order.Number) {
-		return model.ErrInvalidOrderNumber
-	}
+func (os *OrderUseCase) AddOrder(ctx context.Context, order *model.Order) (err error) {
+	ctx, span := startSpan(ctx, "OrderUseCase.AddOrder",
+		attribute.Int("user.id", order.UserID), attribute.String("order.number", order.Number))
+	defer func() { endSpan(span, err) }()
 
-	receivedOrder, err := os.OrderRepository.GetOrderByNumber(ctx, order.Number)
-	if err != nil && !errors.Is(err, model.ErrOrderNotFound) {
+	if err = os.numberValidator.Validate(order.Number); err != nil {
 		return err
 	}
 
-	if receivedOrder != nil {
-		if order.UserID == receivedOrder.UserID {
-			return model.ErrOrderAlreadyExists
-		} else {
-			return model.ErrOrderAlreadyExistsForAnotherUser
-		}
-	}
-
 	order.Status = model.OrderStatusNew
 
 	if err = os.OrderRepository.AddOrder(ctx, order); err != nil {
@@ -45,10 +45,108 @@ func (os *OrderUseCase) AddOrder(ctx context.Context, order *model.Order) error
 	return nil
 }
 
-func (os *OrderUseCase) GetByUser(ctx context.Context, userID int) ([]model.Order, error) {
-	orders, err := os.OrderRepository.GetOrderByUser(ctx, userID)
+func (os *OrderUseCase) AddOrders(ctx context.Context, userID int, numbers []string) (_ []model.OrderBatchResult, err error) {
+	ctx, span := startSpan(ctx, "OrderUseCase.AddOrders",
+		attribute.Int("user.id", userID), attribute.Int("order.batch_size", len(numbers)))
+	defer func() { endSpan(span, err) }()
+
+	if len(numbers) == 0 {
+		return nil, model.ErrEmptyOrderBatch
+	}
+
+	if len(numbers) > os.batchMaxSize {
+		return nil, model.ErrOrderBatchTooLarge
+	}
+
+	results := make([]model.OrderBatchResult, len(numbers))
+	seen := make(map[string]bool, len(numbers))
+	var valid []string
+	for i, number := range numbers {
+		results[i].Number = number
+		if err := os.numberValidator.Validate(number); err != nil {
+			results[i].Status = model.OrderBatchInvalid
+			results[i].Reason = err.Error()
+			var numberErr *model.OrderNumberError
+			if errors.As(err, &numberErr) {
+				results[i].Reason = numberErr.Reason
+			}
+			continue
+		}
+		if !seen[number] {
+			seen[number] = true
+			valid = append(valid, number)
+		}
+	}
+
+	if len(valid) == 0 {
+		return results, nil
+	}
+
+	statuses, err := os.OrderRepository.AddOrders(ctx, userID, valid)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range results {
+		if results[i].Status != "" {
+			continue
+		}
+
+		status := statuses[results[i].Number]
+		if seen[results[i].Number] {
+			seen[results[i].Number] = false
+		} else if status == model.OrderBatchAccepted {
+			status = model.OrderBatchAlreadyYours
+		}
+		results[i].Status = status
+	}
+
+	return results, nil
+}
+
+func (os *OrderUseCase) GetByUser(ctx context.Context, userID int, query model.ListQuery) (_ []model.Order, _ string, err error) {
+	ctx, span := startSpan(ctx, "OrderUseCase.GetByUser", attribute.Int("user.id", userID))
+	defer func() { endSpan(span, err) }()
+
+	orders, err := os.OrderRepository.GetOrderByUser(ctx, userID, query)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if query.Limit <= 0 || len(orders) <= query.Limit {
+		return orders, "", nil
+	}
+
+	orders = orders[:query.Limit]
+	last := orders[len(orders)-1]
+	return orders, model.Cursor{Time: last.UploadedAt, ID: last.ID}.Encode(), nil
+}
+
+func (os *OrderUseCase) GetOrder(ctx context.Context, userID int, number string) (_ *model.Order, err error) {
+	ctx, span := startSpan(ctx, "OrderUseCase.GetOrder",
+		attribute.Int("user.id", userID), attribute.String("order.number", number))
+	defer func() { endSpan(span, err) }()
+
+	order, err := os.OrderRepository.GetOrderByNumber(ctx, number)
 	if err != nil {
 		return nil, err
 	}
-	return orders, nil
+
+	if order.UserID != userID {
+		return nil, model.ErrOrderNotFound
+	}
+
+	return order, nil
+}
+
+func (os *OrderUseCase) GetOrderHistory(ctx context.Context, userID int, number string) (_ []model.OrderEvent, err error) {
+	ctx, span := startSpan(ctx, "OrderUseCase.GetOrderHistory",
+		attribute.Int("user.id", userID), attribute.String("order.number", number))
+	defer func() { endSpan(span, err) }()
+
+	if _, err = os.GetOrder(ctx, userID, number); err != nil {
+		return nil, err
+	}
+
+	return os.OrderRepository.GetOrderEvents(ctx, number)
 }
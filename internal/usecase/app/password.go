@@ -0,0 +1,97 @@
+package app
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/argon2"
+	"strings"
+)
+
+const argon2idAlgorithm = "argon2id"
+
+var errInvalidPasswordHash = errors.New("invalid password hash")
+
+type argon2Params struct {
+	memory     uint32
+	iterations uint32
+	threads    uint8
+	saltLength uint32
+	keyLength  uint32
+}
+
+var defaultArgon2Params = argon2Params{
+	memory:     64 * 1024,
+	iterations: 3,
+	threads:    2,
+	saltLength: 16,
+	keyLength:  32,
+}
+
+func hashArgon2id(password string, params argon2Params) (string, error) {
+	salt := make([]byte, params.saltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, params.iterations, params.memory, params.threads, params.keyLength)
+
+	return fmt.Sprintf("$%s$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2idAlgorithm, argon2.Version,
+		params.memory, params.iterations, params.threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func verifyArgon2id(encoded, password string) (bool, error) {
+	params, salt, key, err := decodeArgon2id(encoded)
+	if err != nil {
+		return false, err
+	}
+
+	otherKey := argon2.IDKey([]byte(password), salt, params.iterations, params.memory, params.threads, params.keyLength)
+
+	return subtle.ConstantTimeCompare(key, otherKey) == 1, nil
+}
+
+func decodeArgon2id(encoded string) (argon2Params, []byte, []byte, error) {
+	var params argon2Params
+
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != argon2idAlgorithm {
+		return params, nil, nil, errInvalidPasswordHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return params, nil, nil, errInvalidPasswordHash
+	}
+	if version != argon2.Version {
+		return params, nil, nil, errInvalidPasswordHash
+	}
+
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.iterations, &params.threads); err != nil {
+		return params, nil, nil, errInvalidPasswordHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, errInvalidPasswordHash
+	}
+	params.saltLength = uint32(len(salt))
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return params, nil, nil, errInvalidPasswordHash
+	}
+	params.keyLength = uint32(len(key))
+
+	return params, salt, key, nil
+}
+
+func isArgon2idHash(encoded string) bool {
+	return strings.HasPrefix(encoded, "$"+argon2idAlgorithm+"$")
+}
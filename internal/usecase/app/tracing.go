@@ -0,0 +1,20 @@
+package app
+
+import (
+	"context"
+	"github.com/invinciblewest/gophermart/internal/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/invinciblewest/gophermart/internal/usecase/app"
+
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+func endSpan(span trace.Span, err error) {
+	tracing.RecordError(span, err)
+	span.End()
+}
@@ -2,9 +2,11 @@ package app
 
 import (
 	"context"
+	"github.com/invinciblewest/gophermart/internal/logger"
 	"github.com/invinciblewest/gophermart/internal/model"
 	"github.com/invinciblewest/gophermart/internal/repository"
 	"github.com/invinciblewest/gophermart/internal/usecase"
+	"go.uber.org/zap"
 )
 
 type UserUseCase struct {
@@ -19,33 +21,63 @@ func NewUserUseCase(userRepository repository.UserRepository, authUseCase usecas
 	}
 }
 
-func (us *UserUseCase) RegisterAndLogin(ctx context.Context, user *model.User) (string, error) {
+func (us *UserUseCase) RegisterAndLogin(ctx context.Context, user *model.User) (_ *model.TokenPair, err error) {
+	ctx, span := startSpan(ctx, "UserUseCase.RegisterAndLogin")
+	defer func() { endSpan(span, err) }()
+
 	if user.Login == "" || user.Password == "" {
-		return "", model.ErrEmptyLoginOrPassword
+		return nil, model.ErrEmptyLoginOrPassword
 	}
 
-	user.Password = us.authUseCase.HashPassword(user.Password)
+	hash, err := us.authUseCase.HashPassword(user.Password)
+	if err != nil {
+		return nil, err
+	}
+	user.Password = hash
 
-	if err := us.userRepository.CreateUser(ctx, user); err != nil {
-		return "", err
+	if err = us.userRepository.CreateUser(ctx, user); err != nil {
+		return nil, err
 	}
 
-	return us.authUseCase.GenerateToken(user.ID)
+	return us.authUseCase.IssueTokens(ctx, user.ID)
 }
 
-func (us *UserUseCase) Login(ctx context.Context, user model.User) (string, error) {
+func (us *UserUseCase) Login(ctx context.Context, user model.User) (_ *model.TokenPair, err error) {
+	ctx, span := startSpan(ctx, "UserUseCase.Login")
+	defer func() { endSpan(span, err) }()
+
 	if user.Login == "" || user.Password == "" {
-		return "", model.ErrEmptyLoginOrPassword
+		return nil, model.ErrEmptyLoginOrPassword
 	}
 
 	receivedUser, err := us.userRepository.GetUserByLogin(ctx, user.Login)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+
+	if !us.authUseCase.VerifyPassword(ctx, receivedUser, user.Password) {
+		return nil, model.ErrInvalidPassword
+	}
+
+	if receivedUser.DisabledAt != nil {
+		return nil, model.ErrUserDisabled
 	}
 
-	if !us.authUseCase.VerifyPassword(receivedUser, user.Password) {
-		return "", model.ErrInvalidPassword
+	if us.authUseCase.NeedsRehash(receivedUser.Password) {
+		us.rehashPassword(ctx, receivedUser.ID, user.Password)
 	}
 
-	return us.authUseCase.GenerateToken(receivedUser.ID)
+	return us.authUseCase.IssueTokens(ctx, receivedUser.ID)
+}
+
+func (us *UserUseCase) rehashPassword(ctx context.Context, userID int, password string) {
+	hash, err := us.authUseCase.HashPassword(password)
+	if err != nil {
+		logger.FromContext(ctx).Error("failed to rehash password", zap.Int("user_id", userID), zap.Error(err))
+		return
+	}
+
+	if err = us.userRepository.UpdatePassword(ctx, userID, hash); err != nil {
+		logger.FromContext(ctx).Error("failed to store rehashed password", zap.Int("user_id", userID), zap.Error(err))
+	}
 }
@@ -6,24 +6,56 @@ import (
 )
 
 type AuthUseCase interface {
-	GenerateToken(userID int) (string, error)
-	ParseToken(tokenStr string) (int, error)
-	HashPassword(password string) string
-	VerifyPassword(user *model.User, password string) bool
+	IssueTokens(ctx context.Context, userID int) (*model.TokenPair, error)
+	RefreshTokens(ctx context.Context, refreshToken string) (*model.TokenPair, error)
+	RevokeTokens(ctx context.Context, claims *model.TokenClaims, refreshToken string) error
+	ParseToken(ctx context.Context, tokenStr string) (*model.TokenClaims, error)
+	PurgeExpiredTokens(ctx context.Context) (int64, error)
+	JWKS() model.JWKS
+	HashPassword(password string) (string, error)
+	VerifyPassword(ctx context.Context, user *model.User, password string) bool
+	NeedsRehash(hash string) bool
 }
 
 type OrderUseCase interface {
 	AddOrder(ctx context.Context, order *model.Order) error
-	GetByUser(ctx context.Context, userID int) ([]model.Order, error)
+	AddOrders(ctx context.Context, userID int, numbers []string) ([]model.OrderBatchResult, error)
+	GetByUser(ctx context.Context, userID int, query model.ListQuery) ([]model.Order, string, error)
+	GetOrder(ctx context.Context, userID int, number string) (*model.Order, error)
+	GetOrderHistory(ctx context.Context, userID int, number string) ([]model.OrderEvent, error)
 }
 
 type UserUseCase interface {
-	RegisterAndLogin(ctx context.Context, user *model.User) (string, error)
-	Login(ctx context.Context, user model.User) (string, error)
+	RegisterAndLogin(ctx context.Context, user *model.User) (*model.TokenPair, error)
+	Login(ctx context.Context, user model.User) (*model.TokenPair, error)
 }
 
 type BalanceUseCase interface {
 	GetUserBalance(ctx context.Context, userID int) (*model.Balance, error)
 	WithdrawBalance(ctx context.Context, userID int, request model.WithdrawRequest) error
-	GetWithdrawals(ctx context.Context, userID int) ([]model.Withdrawal, error)
+	GetWithdrawals(ctx context.Context, userID int, query model.ListQuery) ([]model.Withdrawal, string, error)
+}
+
+type IdempotencyUseCase interface {
+	Reserve(ctx context.Context, record *model.IdempotencyRecord) (*model.IdempotencyRecord, error)
+	Complete(ctx context.Context, record *model.IdempotencyRecord) error
+	Release(ctx context.Context, record *model.IdempotencyRecord) error
+	Block(ctx context.Context, record *model.IdempotencyRecord) error
+	Purge(ctx context.Context) (int64, error)
+}
+
+type HealthUseCase interface {
+	Live() model.HealthReport
+	Ready(ctx context.Context) model.HealthReport
+	SetShuttingDown()
+}
+
+type AdminUseCase interface {
+	CreateUser(ctx context.Context, login string, password string) (*model.User, error)
+	DisableUser(ctx context.Context, login string) error
+	ResetPassword(ctx context.Context, login string, password string) error
+	RequeueOrder(ctx context.Context, number string) error
+	RecomputeBalance(ctx context.Context, login string) (*model.BalanceDrift, error)
+	ReconcileBalances(ctx context.Context, fix bool) ([]model.BalanceDrift, error)
+	Export(ctx context.Context, fn func(model.UserExport) error) error
 }
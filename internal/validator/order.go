@@ -0,0 +1,133 @@
+package validator
+
+import (
+	"fmt"
+	"github.com/invinciblewest/gophermart/internal/model"
+	"regexp"
+	"strings"
+)
+
+type OrderNumberValidator interface {
+	Validate(number string) error
+}
+
+type Luhn struct{}
+
+func (Luhn) Validate(number string) error {
+	if number == "" {
+		return invalid("order number is empty")
+	}
+
+	var sum int
+	double := false
+
+	for i := len(number) - 1; i >= 0; i-- {
+		r := number[i]
+
+		if r < '0' || r > '9' {
+			return invalid("order number must contain only digits")
+		}
+
+		digit := int(r - '0')
+
+		if double {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+
+		sum += digit
+		double = !double
+	}
+
+	if sum%10 != 0 {
+		return invalid("order number fails the Luhn checksum")
+	}
+
+	return nil
+}
+
+type Length struct {
+	Min int
+	Max int
+}
+
+func (l Length) Validate(number string) error {
+	if len(number) >= l.Min && (l.Max == 0 || len(number) <= l.Max) {
+		return nil
+	}
+
+	switch {
+	case l.Max == 0:
+		return invalid(fmt.Sprintf("order number must be at least %d characters long", l.Min))
+	case l.Min == l.Max:
+		return invalid(fmt.Sprintf("order number must be exactly %d characters long", l.Min))
+	default:
+		return invalid(fmt.Sprintf("order number length must be between %d and %d", l.Min, l.Max))
+	}
+}
+
+type Regex struct {
+	Pattern *regexp.Regexp
+}
+
+func (r Regex) Validate(number string) error {
+	if !r.Pattern.MatchString(number) {
+		return invalid(fmt.Sprintf("order number must match %s", r.Pattern))
+	}
+	return nil
+}
+
+type Format struct {
+	Storefront string
+	Rules      All
+}
+
+func (f Format) Validate(number string) error {
+	if err := f.Rules.Validate(number); err != nil {
+		return invalid(f.Storefront + ": " + reason(err))
+	}
+	return nil
+}
+
+type All []OrderNumberValidator
+
+func (a All) Validate(number string) error {
+	for _, v := range a {
+		if err := v.Validate(number); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type Any []OrderNumberValidator
+
+func (a Any) Validate(number string) error {
+	if len(a) == 1 {
+		return a[0].Validate(number)
+	}
+
+	reasons := make([]string, 0, len(a))
+	for _, v := range a {
+		err := v.Validate(number)
+		if err == nil {
+			return nil
+		}
+		reasons = append(reasons, reason(err))
+	}
+
+	return invalid("order number matches no accepted format (" + strings.Join(reasons, "; ") + ")")
+}
+
+func invalid(reason string) error {
+	return &model.OrderNumberError{Reason: reason}
+}
+
+func reason(err error) string {
+	if numberErr, ok := err.(*model.OrderNumberError); ok {
+		return numberErr.Reason
+	}
+	return err.Error()
+}
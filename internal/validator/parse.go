@@ -0,0 +1,83 @@
+package validator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+type formatConfig struct {
+	Storefront string       `json:"storefront"`
+	Rules      []ruleConfig `json:"rules"`
+}
+
+type ruleConfig struct {
+	Type    string `json:"type"`
+	Min     int    `json:"min"`
+	Max     int    `json:"max"`
+	Pattern string `json:"pattern"`
+}
+
+func Parse(spec string) (OrderNumberValidator, error) {
+	var configs []formatConfig
+	if err := json.Unmarshal([]byte(spec), &configs); err != nil {
+		return nil, fmt.Errorf("invalid order number rules: %w", err)
+	}
+	if len(configs) == 0 {
+		return nil, errors.New("no order number formats configured")
+	}
+
+	if len(configs) == 1 {
+		return parseFormat(configs[0])
+	}
+
+	formats := make(Any, 0, len(configs))
+	for _, config := range configs {
+		rules, err := parseFormat(config)
+		if err != nil {
+			return nil, err
+		}
+		formats = append(formats, Format{Storefront: config.Storefront, Rules: rules})
+	}
+	return formats, nil
+}
+
+func parseFormat(config formatConfig) (All, error) {
+	if config.Storefront == "" {
+		return nil, errors.New("order number format has no storefront")
+	}
+	if len(config.Rules) == 0 {
+		return nil, fmt.Errorf("order number format for storefront %q has no rules", config.Storefront)
+	}
+
+	rules := make(All, 0, len(config.Rules))
+	for _, rule := range config.Rules {
+		v, err := parseRule(rule)
+		if err != nil {
+			return nil, fmt.Errorf("storefront %q: %w", config.Storefront, err)
+		}
+		rules = append(rules, v)
+	}
+	return rules, nil
+}
+
+func parseRule(rule ruleConfig) (OrderNumberValidator, error) {
+	switch rule.Type {
+	case "luhn":
+		return Luhn{}, nil
+	case "length":
+		if rule.Min < 0 || rule.Max < 0 || (rule.Max > 0 && rule.Max < rule.Min) {
+			return nil, fmt.Errorf("invalid length rule: min %d, max %d", rule.Min, rule.Max)
+		}
+		return Length{Min: rule.Min, Max: rule.Max}, nil
+	case "regex":
+		pattern, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex rule %q: %w", rule.Pattern, err)
+		}
+		return Regex{Pattern: pattern}, nil
+	default:
+		return nil, fmt.Errorf("unknown order number rule %q", rule.Type)
+	}
+}
@@ -0,0 +1,85 @@
+package validator
+
+import (
+	"errors"
+	"github.com/invinciblewest/gophermart/internal/model"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	const (
+		luhn        = `{"type":"luhn"}`
+		tenDigits   = `{"type":"length","min":10,"max":10}`
+		digitsOnly  = `{"type":"regex","pattern":"^[0-9]+$"}`
+		shopPrefix  = `{"type":"regex","pattern":"^SHOP-[0-9]{4}$"}`
+		luhnFormat  = `{"storefront":"web","rules":[` + luhn + `]}`
+		shopFormat  = `{"storefront":"shop","rules":[` + shopPrefix + `]}`
+		validLuhn   = "12345678903"
+		invalidLuhn = "12345678904"
+	)
+
+	tests := []struct {
+		name     string
+		spec     string
+		number   string
+		parseErr string
+		reason   string
+	}{
+		{name: "luhn accepts a valid number", spec: `[` + luhnFormat + `]`, number: validLuhn},
+		{name: "luhn rejects a bad checksum", spec: `[` + luhnFormat + `]`, number: invalidLuhn, reason: "Luhn checksum"},
+		{name: "length accepts an exact length", spec: `[{"storefront":"web","rules":[` + tenDigits + `]}]`, number: "9278923470"},
+		{name: "length rejects a longer number", spec: `[{"storefront":"web","rules":[` + tenDigits + `]}]`, number: validLuhn,
+			reason: "exactly 10"},
+		{name: "length with only a minimum", spec: `[{"storefront":"web","rules":[{"type":"length","min":12}]}]`, number: validLuhn,
+			reason: "at least 12"},
+		{name: "length range", spec: `[{"storefront":"web","rules":[{"type":"length","min":3,"max":5}]}]`, number: validLuhn,
+			reason: "between 3 and 5"},
+		{name: "regex accepts a matching number", spec: `[{"storefront":"web","rules":[` + digitsOnly + `]}]`, number: "0042"},
+		{name: "regex rejects a mismatch", spec: `[{"storefront":"web","rules":[` + digitsOnly + `]}]`, number: "42a",
+			reason: "must match"},
+		{name: "rules apply in order", spec: `[{"storefront":"web","rules":[` + tenDigits + `,` + luhn + `]}]`, number: "9278923471",
+			reason: "Luhn checksum"},
+		{name: "any storefront may match the first", spec: `[` + luhnFormat + `,` + shopFormat + `]`, number: validLuhn},
+		{name: "any storefront may match the second", spec: `[` + luhnFormat + `,` + shopFormat + `]`, number: "SHOP-1234"},
+		{name: "no storefront matches", spec: `[` + luhnFormat + `,` + shopFormat + `]`, number: invalidLuhn,
+			reason: "matches no accepted format"},
+		{name: "invalid regex", spec: `[{"storefront":"web","rules":[{"type":"regex","pattern":"[0-9"}]}]`,
+			parseErr: "invalid regex rule"},
+		{name: "unknown rule type", spec: `[{"storefront":"web","rules":[{"type":"crc"}]}]`,
+			parseErr: `unknown order number rule "crc"`},
+		{name: "invalid length bounds", spec: `[{"storefront":"web","rules":[{"type":"length","min":5,"max":3}]}]`,
+			parseErr: "invalid length rule"},
+		{name: "missing storefront", spec: `[{"rules":[` + luhn + `]}]`, parseErr: "has no storefront"},
+		{name: "format without rules", spec: `[{"storefront":"web","rules":[]}]`, parseErr: "has no rules"},
+		{name: "no formats", spec: `[]`, parseErr: "no order number formats"},
+		{name: "malformed json", spec: `{`, parseErr: "invalid order number rules"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := Parse(tt.spec)
+			if tt.parseErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.parseErr) {
+					t.Fatalf("Parse() error = %v, want it to contain %q", err, tt.parseErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+
+			err = v.Validate(tt.number)
+			if tt.reason == "" {
+				if err != nil {
+					t.Errorf("Validate(%q) error = %v", tt.number, err)
+				}
+				return
+			}
+			var numberErr *model.OrderNumberError
+			if !errors.As(err, &numberErr) || !strings.Contains(numberErr.Reason, tt.reason) {
+				t.Errorf("Validate(%q) error = %v, want a reason containing %q", tt.number, err, tt.reason)
+			}
+		})
+	}
+}
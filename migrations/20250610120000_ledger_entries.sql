@@ -0,0 +1,38 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE "ledger_entries" (
+    "id" bigserial PRIMARY KEY,
+    "user_id" int NOT NULL REFERENCES "users" ("id"),
+    "kind" varchar(20) NOT NULL,
+    "account" varchar(20) NOT NULL,
+    "direction" varchar(10) NOT NULL CHECK ("direction" IN ('debit', 'credit')),
+    "amount" int NOT NULL CHECK ("amount" > 0),
+    "order_number" varchar(50) NOT NULL,
+    "created_at" timestamptz DEFAULT (now())
+);
+
+CREATE INDEX "ledger_entries_user_account_idx" ON "ledger_entries" ("user_id", "account");
+CREATE UNIQUE INDEX "ledger_entries_accrual_uniq" ON "ledger_entries" ("order_number", "account")
+    WHERE "kind" = 'accrual';
+
+INSERT INTO "ledger_entries" ("user_id", "kind", "account", "direction", "amount", "order_number", "created_at")
+SELECT "user_id", 'accrual', 'accruals', 'debit', "accrual", "number", "uploaded_at"
+FROM "orders" WHERE "status" = 'PROCESSED' AND "accrual" > 0;
+
+INSERT INTO "ledger_entries" ("user_id", "kind", "account", "direction", "amount", "order_number", "created_at")
+SELECT "user_id", 'accrual', 'user', 'credit', "accrual", "number", "uploaded_at"
+FROM "orders" WHERE "status" = 'PROCESSED' AND "accrual" > 0;
+
+INSERT INTO "ledger_entries" ("user_id", "kind", "account", "direction", "amount", "order_number", "created_at")
+SELECT "user_id", 'withdrawal', 'user', 'debit', "amount", "order_number", "processed_at"
+FROM "withdrawals" WHERE "amount" > 0;
+
+INSERT INTO "ledger_entries" ("user_id", "kind", "account", "direction", "amount", "order_number", "created_at")
+SELECT "user_id", 'withdrawal', 'withdrawals', 'credit', "amount", "order_number", "processed_at"
+FROM "withdrawals" WHERE "amount" > 0;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE "ledger_entries";
+-- +goose StatementEnd
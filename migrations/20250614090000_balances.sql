@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE "balances" (
+    "user_id" int PRIMARY KEY REFERENCES "users" ("id"),
+    "current" int NOT NULL DEFAULT 0,
+    "withdrawn" int NOT NULL DEFAULT 0,
+    "updated_at" timestamptz DEFAULT (now())
+);
+
+INSERT INTO "balances" ("user_id", "current", "withdrawn")
+SELECT "user_id",
+       COALESCE(SUM(CASE WHEN "account" = 'user' AND "direction" = 'credit' THEN "amount"
+                         WHEN "account" = 'user' AND "direction" = 'debit' THEN -"amount" END), 0),
+       COALESCE(SUM(CASE WHEN "account" = 'withdrawals' AND "direction" = 'credit' THEN "amount" END), 0)
+FROM "ledger_entries"
+GROUP BY "user_id";
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE "balances";
+-- +goose StatementEnd
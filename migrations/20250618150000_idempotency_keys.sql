@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE "idempotency_keys" (
+    "user_id" int NOT NULL,
+    "key" varchar(255) NOT NULL,
+    "request_hash" varchar(64) NOT NULL,
+    "status_code" int,
+    "response_header" jsonb,
+    "response_body" bytea,
+    "created_at" timestamptz DEFAULT (now()),
+    PRIMARY KEY ("user_id", "key")
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE "idempotency_keys";
+-- +goose StatementEnd
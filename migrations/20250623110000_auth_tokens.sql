@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE "refresh_tokens" (
+    "id" serial PRIMARY KEY,
+    "user_id" int NOT NULL REFERENCES "users" ("id"),
+    "family_id" varchar(64) NOT NULL,
+    "token_hash" varchar(64) UNIQUE NOT NULL,
+    "expires_at" timestamptz NOT NULL,
+    "used_at" timestamptz,
+    "revoked_at" timestamptz,
+    "created_at" timestamptz DEFAULT (now())
+);
+
+CREATE INDEX "refresh_tokens_family_idx" ON "refresh_tokens" ("family_id");
+
+CREATE TABLE "revoked_tokens" (
+    "jti" varchar(64) PRIMARY KEY,
+    "expires_at" timestamptz NOT NULL,
+    "revoked_at" timestamptz DEFAULT (now())
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE "revoked_tokens";
+DROP TABLE "refresh_tokens";
+-- +goose StatementEnd
@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE "accrual_jobs" (
+    "order_number" varchar(50) PRIMARY KEY REFERENCES "orders" ("number") ON DELETE CASCADE,
+    "attempts" int NOT NULL DEFAULT 0,
+    "next_attempt_at" timestamptz NOT NULL DEFAULT (now()),
+    "locked_by" varchar(255),
+    "locked_until" timestamptz,
+    "created_at" timestamptz DEFAULT (now()),
+    "updated_at" timestamptz DEFAULT (now())
+);
+
+CREATE INDEX "accrual_jobs_next_attempt_idx" ON "accrual_jobs" ("next_attempt_at");
+
+INSERT INTO "accrual_jobs" ("order_number")
+SELECT "number" FROM "orders" WHERE "status" IN ('NEW', 'PROCESSING');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE "accrual_jobs";
+-- +goose StatementEnd
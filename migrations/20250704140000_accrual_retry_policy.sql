@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE "orders" ADD COLUMN "status_reason" text;
+ALTER TABLE "accrual_jobs" ADD COLUMN "last_error" text;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE "accrual_jobs" DROP COLUMN "last_error";
+ALTER TABLE "orders" DROP COLUMN "status_reason";
+-- +goose StatementEnd
@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE "order_events" (
+    "id" bigserial PRIMARY KEY,
+    "order_number" varchar(50) NOT NULL REFERENCES "orders" ("number") ON DELETE CASCADE,
+    "from_status" varchar(20),
+    "to_status" varchar(20) NOT NULL,
+    "accrual" int,
+    "reason" text,
+    "source" varchar(20) NOT NULL,
+    "raw_response" jsonb,
+    "created_at" timestamptz DEFAULT (now())
+);
+
+CREATE INDEX "order_events_order_idx" ON "order_events" ("order_number", "created_at");
+
+INSERT INTO "order_events" ("order_number", "to_status", "source", "created_at")
+SELECT "number", 'NEW', 'backfill', "uploaded_at" FROM "orders";
+
+INSERT INTO "order_events" ("order_number", "from_status", "to_status", "accrual", "reason", "source")
+SELECT "number", 'NEW', "status", "accrual", "status_reason", 'backfill' FROM "orders" WHERE "status" <> 'NEW';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE "order_events";
+-- +goose StatementEnd
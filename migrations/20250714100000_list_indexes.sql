@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE INDEX "orders_user_uploaded_idx" ON "orders" ("user_id", "uploaded_at" DESC, "id" DESC);
+CREATE INDEX "withdrawals_user_processed_idx" ON "withdrawals" ("user_id", "processed_at" DESC, "id" DESC);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX "withdrawals_user_processed_idx";
+DROP INDEX "orders_user_uploaded_idx";
+-- +goose StatementEnd
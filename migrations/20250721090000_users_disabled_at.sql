@@ -0,0 +1,9 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE "users" ADD COLUMN "disabled_at" timestamptz;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE "users" DROP COLUMN "disabled_at";
+-- +goose StatementEnd
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE "idempotency_keys" ADD COLUMN "locked_until" timestamptz;
+CREATE INDEX "idempotency_keys_created_idx" ON "idempotency_keys" ("created_at");
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX "idempotency_keys_created_idx";
+ALTER TABLE "idempotency_keys" DROP COLUMN "locked_until";
+-- +goose StatementEnd
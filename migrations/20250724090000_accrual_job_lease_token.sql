@@ -0,0 +1,9 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE "accrual_jobs" ADD COLUMN "lease_token" varchar(64);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE "accrual_jobs" DROP COLUMN "lease_token";
+-- +goose StatementEnd
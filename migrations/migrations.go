@@ -0,0 +1,53 @@
+package migrations
+
+import (
+	"embed"
+	"fmt"
+	"github.com/pressly/goose"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+//go:embed *.sql
+var files embed.FS
+
+func LatestVersion() (int64, error) {
+	names, err := fs.Glob(files, "*.sql")
+	if err != nil {
+		return 0, err
+	}
+
+	var latest int64
+	for _, name := range names {
+		version, err := goose.NumericComponent(name)
+		if err != nil {
+			return 0, fmt.Errorf("invalid migration %s: %w", name, err)
+		}
+		latest = max(latest, version)
+	}
+	return latest, nil
+}
+
+func Extract() (string, error) {
+	dir, err := os.MkdirTemp("", "gophermart-migrations-")
+	if err != nil {
+		return "", err
+	}
+
+	err = fs.WalkDir(files, ".", func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		data, err := files.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(filepath.Join(dir, path), data, 0o600)
+	})
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return "", err
+	}
+	return dir, nil
+}